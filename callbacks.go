@@ -0,0 +1,160 @@
+package roamer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// A CallbackType describes when a callback file is run, relative to the migrations applied by an Operation.
+type CallbackType string
+
+// The available callback types.
+const (
+	CallbackBeforeAll  CallbackType = "beforeAll"
+	CallbackBeforeEach CallbackType = "beforeEach"
+	CallbackAfterEach  CallbackType = "afterEach"
+	CallbackAfterAll   CallbackType = "afterAll"
+)
+
+// A CallbackError is returned when there's an error while running a callback file.
+type CallbackError struct {
+	Type     CallbackType
+	Filename string
+	Inner    error
+}
+
+// Error returns a string representation of the CallbackError.
+func (e CallbackError) Error() string {
+	return fmt.Sprintf("roamer: %s callback %s: %s", e.Type, e.Filename, e.Inner.Error())
+}
+
+// Unwrap returns the inner error of the CallbackError.
+func (e CallbackError) Unwrap() error {
+	return e.Inner
+}
+
+// A callback is a callback file that has been read, ready to be run.
+type callback struct {
+	Type     CallbackType
+	Filename string
+	Contents string
+}
+
+// callbackFilename returns the name of the file used for the given type of callback and direction, or an empty string if that callback is disabled.
+func (e *Environment) callbackFilename(callbackType CallbackType, direction Direction) string {
+	baseName := ""
+	switch callbackType {
+	case CallbackBeforeAll:
+		baseName = e.Config.Callbacks.BeforeAll
+	case CallbackBeforeEach:
+		baseName = e.Config.Callbacks.BeforeEach
+	case CallbackAfterEach:
+		baseName = e.Config.Callbacks.AfterEach
+	case CallbackAfterAll:
+		baseName = e.Config.Callbacks.AfterAll
+	}
+
+	if baseName == "" {
+		return ""
+	}
+
+	return baseName + "_" + direction.String() + ".sql"
+}
+
+// IsCallbackFilename returns true if the given filename, in the migrations directory, is used by any callback, in either direction.
+func (e *Environment) IsCallbackFilename(filename string) bool {
+	for _, callbackType := range []CallbackType{CallbackBeforeAll, CallbackBeforeEach, CallbackAfterEach, CallbackAfterAll} {
+		for _, direction := range []Direction{DirectionUp, DirectionDown} {
+			callbackFilename := e.callbackFilename(callbackType, direction)
+			if callbackFilename != "" && callbackFilename == filename {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// readCallback reads the callback file of the given type and direction, returning nil if there is no such file.
+func (e *Environment) readCallback(callbackType CallbackType, direction Direction) (*callback, error) {
+	filename := e.callbackFilename(callbackType, direction)
+	if filename == "" || !e.callbackFiles[filename] {
+		return nil, nil
+	}
+
+	data, err := e.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &callback{
+		Type:     callbackType,
+		Filename: filename,
+		Contents: string(data),
+	}, nil
+}
+
+// run runs the callback, using the given connection.
+func (c *callback) run(ctx context.Context, conn execer, logger *slog.Logger) error {
+	if c == nil {
+		return nil
+	}
+
+	startTime := time.Now()
+	_, err := conn.ExecContext(ctx, c.Contents)
+	if err != nil {
+		return CallbackError{
+			Type:     c.Type,
+			Filename: c.Filename,
+			Inner:    err,
+		}
+	}
+
+	logger.Debug("roamer: ran callback", "filename", c.Filename, "duration", time.Since(startTime))
+
+	return nil
+}
+
+// contents returns the SQL of the callback, or an empty string if there is no callback.
+func (c *callback) contents() string {
+	if c == nil {
+		return ""
+	}
+
+	return c.Contents
+}
+
+// operationCallbacks contains the callbacks that are run during an Operation.
+type operationCallbacks struct {
+	beforeAll  *callback
+	beforeEach *callback
+	afterEach  *callback
+	afterAll   *callback
+}
+
+// readCallbacks reads all of the callbacks that are run during an operation in the given direction.
+func (e *Environment) readCallbacks(direction Direction) (operationCallbacks, error) {
+	result := operationCallbacks{}
+	var err error
+
+	result.beforeAll, err = e.readCallback(CallbackBeforeAll, direction)
+	if err != nil {
+		return operationCallbacks{}, err
+	}
+	result.beforeEach, err = e.readCallback(CallbackBeforeEach, direction)
+	if err != nil {
+		return operationCallbacks{}, err
+	}
+	result.afterEach, err = e.readCallback(CallbackAfterEach, direction)
+	if err != nil {
+		return operationCallbacks{}, err
+	}
+	result.afterAll, err = e.readCallback(CallbackAfterAll, direction)
+	if err != nil {
+		return operationCallbacks{}, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,152 @@
+package roamer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// migrationChecksum returns the checksum of the given migration file, which is recorded in the history table when the
+// migration is applied. Line endings are normalized first, so that a checkout that converts them doesn't look like a change.
+func migrationChecksum(migrationData []byte) string {
+	normalized := bytes.ReplaceAll(migrationData, []byte("\r\n"), []byte("\n"))
+	sum := sha256.Sum256(normalized)
+	return hex.EncodeToString(sum[:])
+}
+
+// addChecksumColumnStatement returns the statement that adds the checksum column to the given history table.
+func addChecksumColumnStatement(table string) string {
+	return "ALTER TABLE " + table + " ADD COLUMN checksum VARCHAR(64)"
+}
+
+// checksumStatement returns the statement that records the checksum of the given migration in the given history table.
+func checksumStatement(table string, migration Migration, checksum string) statement {
+	return statement{
+		"UPDATE " + table + " SET checksum = ? WHERE id = ?",
+		[]interface{}{checksum, migration.ID},
+	}
+}
+
+// hasChecksumColumn returns true if the history table has the checksum column, which older versions of roamer did not create.
+func (e *Environment) hasChecksumColumn(ctx context.Context, conn execer) (bool, error) {
+	columns, err := e.driver.TableColumns(ctx, conn, e.historyTable)
+	if err != nil {
+		return false, err
+	}
+
+	for _, column := range columns {
+		if strings.EqualFold(column, "checksum") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// addChecksumColumn adds the checksum column to an existing history table that doesn't have it yet.
+func (e *Environment) addChecksumColumn(ctx context.Context, conn execer) error {
+	hasColumn, err := e.hasChecksumColumn(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if hasColumn {
+		return nil
+	}
+
+	_, err = conn.ExecContext(ctx, addChecksumColumnStatement(e.historyTable))
+	if err != nil {
+		return err
+	}
+
+	e.logger.Info("roamer: added checksum column to history table", "table", e.historyTable)
+
+	return nil
+}
+
+// appliedChecksums returns the checksums recorded in the history table, by migration ID.
+// Migrations that were applied without recording a checksum are left out.
+func (e *Environment) appliedChecksums(ctx context.Context) (map[string]string, error) {
+	result := map[string]string{}
+
+	tableExists, err := e.driver.TableExists(ctx, e.db, e.historyTable)
+	if err != nil {
+		return nil, err
+	}
+	if !tableExists {
+		return result, nil
+	}
+
+	hasColumn, err := e.hasChecksumColumn(ctx, e.db)
+	if err != nil {
+		return nil, err
+	}
+	if !hasColumn {
+		return result, nil
+	}
+
+	rows, err := e.db.QueryContext(ctx, "SELECT id, checksum FROM "+e.historyTable+" WHERE checksum IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		id := ""
+		checksum := ""
+		err = rows.Scan(&id, &checksum)
+		if err != nil {
+			return nil, err
+		}
+
+		result[id] = checksum
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// VerifyChecksums checks that the up files of the applied migrations have not changed since they were applied.
+// Migrations that were applied without recording a checksum, such as by an older version of roamer, are skipped, as are
+// applied migrations that do not exist on disk, which VerifyExist reports instead.
+func (e *Environment) VerifyChecksums() (VerificationReport, error) {
+	return e.VerifyChecksumsContext(context.Background())
+}
+
+// VerifyChecksumsContext checks that the up files of the applied migrations have not changed since they were applied,
+// using the given context.
+func (e *Environment) VerifyChecksumsContext(ctx context.Context) (VerificationReport, error) {
+	if e.db == nil {
+		return VerificationReport{}, ErrEnvironmentOffline
+	}
+
+	checksums, err := e.appliedChecksums(ctx)
+	if err != nil {
+		return VerificationReport{}, err
+	}
+
+	report := VerificationReport{}
+	for _, migration := range e.migrations {
+		checksum, applied := checksums[migration.ID]
+		if !applied {
+			continue
+		}
+
+		migrationData, err := e.readMigrationFile(migration, DirectionUp)
+		if err != nil {
+			return VerificationReport{}, err
+		}
+
+		if migrationChecksum(migrationData) != checksum {
+			e.logger.Warn("roamer: verification failed, migration has changed since it was applied", "id", migration.ID)
+			report.add(VerificationProblem{Kind: ErrMigrationChanged, ID: migration.ID})
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+// A namedEnvironment is one of several environments that a command is run on at once.
+type namedEnvironment struct {
+	name        string
+	heading     string
+	environment *roamer.Environment
+}
+
+// streamEnvironments loads an environment for every stream of migrations, starting with the given default environment.
+func streamEnvironments(environment *roamer.Environment, basePath string, localConfigName string, options []roamer.EnvironmentOption) ([]namedEnvironment, error) {
+	environments := []namedEnvironment{{roamer.DefaultName, "Stream: " + roamer.DefaultName, environment}}
+	for _, name := range environment.Config.StreamNames() {
+		streamEnvironment, err := roamer.NewEnvironmentFromDisk(basePath, localConfigName, append(options, roamer.WithStream(name))...)
+		if err != nil {
+			return nil, err
+		}
+
+		environments = append(environments, namedEnvironment{name, "Stream: " + name, streamEnvironment})
+	}
+
+	return environments, nil
+}
+
+// databaseEnvironments loads an environment for every database, starting with the given default environment.
+func databaseEnvironments(environment *roamer.Environment, basePath string, localConfigName string, options []roamer.EnvironmentOption) ([]namedEnvironment, error) {
+	environments := []namedEnvironment{{roamer.DefaultName, "Database: " + roamer.DefaultName, environment}}
+	for _, name := range environment.Config.DatabaseNames() {
+		databaseEnvironment, err := roamer.NewEnvironmentFromDisk(basePath, localConfigName, append(options, roamer.WithDatabase(name))...)
+		if err != nil {
+			return nil, err
+		}
+
+		environments = append(environments, namedEnvironment{name, "Database: " + name, databaseEnvironment})
+	}
+
+	return environments, nil
+}
+
+func commandUpgradeAll(ctx context.Context, environments []namedEnvironment, options commandOptions) error {
+	for i, environment := range environments {
+		if i != 0 {
+			fmt.Println()
+		}
+		fmt.Println(environment.heading)
+
+		allMigrations, err := environment.environment.ListAllMigrations()
+		if err != nil {
+			return err
+		}
+		if len(allMigrations) == 0 {
+			// an empty stream isn't a reason to stop upgrading the others
+			fmt.Println("There are no migrations.")
+			continue
+		}
+
+		err = commandUpgrade(ctx, environment.environment, options, []string{})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func commandStatusAll(ctx context.Context, environments []namedEnvironment, options commandOptions) error {
+	ok := true
+
+	if options.format == "json" {
+		output := map[string]statusOutput{}
+		for _, environment := range environments {
+			environmentOutput, environmentOK, err := getStatusOutput(ctx, environment.environment)
+			if err != nil {
+				return err
+			}
+
+			output[environment.name] = environmentOutput
+			ok = ok && environmentOK
+		}
+
+		err := printJSON(output)
+		if err != nil {
+			return err
+		}
+	} else {
+		for i, environment := range environments {
+			if i != 0 {
+				fmt.Println()
+			}
+			fmt.Println(environment.heading)
+
+			environmentOK, err := printStatus(ctx, environment.environment, options)
+			if err != nil {
+				return err
+			}
+
+			ok = ok && environmentOK
+		}
+	}
+
+	if !ok {
+		return exitError{exitCodeProblem}
+	}
+
+	return nil
+}
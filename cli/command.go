@@ -1,24 +1,45 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/thatoddmailbox/roamer"
 )
 
-type commandAction func(*roamer.Environment, commandOptions, []string)
+type commandAction func(context.Context, *roamer.Environment, commandOptions, []string) error
+type commandAllAction func(context.Context, []namedEnvironment, commandOptions) error
 
 type command struct {
 	Name        string
 	Description string
 	Arguments   []string
 	Action      commandAction
+
+	// AllAction runs the command on several environments at once, if the command supports that.
+	AllAction commandAllAction
 }
 
 type commandOptions struct {
-	force bool
-	stamp bool
+	force        bool
+	stamp        bool
+	timeout      time.Duration
+	dryRun       bool
+	dryRunFormat string
+	format       string
+	targets      string
+	dsnTemplate  string
+	concurrency  int
+	failFast     bool
+	offline      bool
+	baseRef      string
+
+	// these are for commands that load the environment themselves
+	environmentPath    string
+	localConfig        string
+	environmentOptions []roamer.EnvironmentOption
 }
 
 var commands map[string]command
@@ -39,6 +60,18 @@ func registerCommands() {
 		Arguments:   []string{"NAME"},
 		Action:      commandCreate,
 	})
+	registerCommand(command{
+		Name:        "doctor",
+		Description: "Checks the environment, its migrations, and its database for problems, reporting all of them at once",
+		Arguments:   []string{},
+		Action:      commandDoctor,
+	})
+	registerCommand(command{
+		Name:        "fleet",
+		Description: "Upgrades every database listed in the -targets file, using the migrations of the environment",
+		Arguments:   []string{"upgrade"},
+		Action:      commandFleet,
+	})
 	registerCommand(command{
 		Name:        "go",
 		Description: "Migrates the database to the given migration",
@@ -51,62 +84,93 @@ func registerCommands() {
 		Arguments:   []string{},
 		Action:      commandInit,
 	})
+	registerCommand(command{
+		Name:        "lint",
+		Description: "Checks the migration files for SQL that is often a mistake, using the rules in the Lint section of roamer.toml",
+		Arguments:   []string{},
+		Action:      commandLint,
+	})
+	registerCommand(command{
+		Name:        "merge",
+		Description: "Create a new migration that merges the heads of the revision graph",
+		Arguments:   []string{"NAME"},
+		Action:      commandMerge,
+	})
 	registerCommand(command{
 		Name:        "setup",
 		Description: "Sets up an existing environment with database configuration options",
 		Arguments:   []string{},
 		Action:      commandSetup,
 	})
+	registerCommand(command{
+		Name:        "sql",
+		Description: "Prints a SQL script that migrates a database between the given migrations, without connecting to it",
+		Arguments:   []string{"FROM MIGRATION ID", "TO MIGRATION ID"},
+		Action:      commandSQL,
+	})
 	registerCommand(command{
 		Name:        "status",
 		Description: "Gets the currently applied migration in the database",
 		Arguments:   []string{},
 		Action:      commandStatus,
+		AllAction:   commandStatusAll,
 	})
 	registerCommand(command{
 		Name:        "upgrade",
 		Description: "Upgrade the database to the latest version",
 		Arguments:   []string{},
 		Action:      commandUpgrade,
+		AllAction:   commandUpgradeAll,
+	})
+	registerCommand(command{
+		Name:        "verify",
+		Description: "Checks the migration files, and unless -offline is given the database, for problems, for use in CI",
+		Arguments:   []string{},
+		Action:      commandVerify,
 	})
 }
 
-func requireSafe(environment *roamer.Environment) error {
-	isClean, err := environment.VerifyNoDirty()
-	if err != nil {
-		return err
-	}
-
-	if !isClean {
-		fmt.Println("One or more migrations are marked as dirty.")
+func requireSafe(ctx context.Context, environment *roamer.Environment) error {
+	heads := headIDs(environment)
+	if len(heads) > 1 {
+		fmt.Printf("The revision graph has multiple heads: %s.\n", strings.Join(heads, ", "))
 		fmt.Println("It is not safe to apply additional migrations at this time.")
-		fmt.Println("For more information, and help resolving the issue, do `roamer status`.")
-		os.Exit(1)
+		fmt.Println("Merge them by doing `roamer merge <description>`.")
+		return exitError{exitCodeNotSafe}
 	}
 
-	allExist, err := environment.VerifyExist()
+	report, err := environment.VerifySafeToApplyContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	if !allExist {
-		fmt.Println("There are migrations in the database that do not exist on disk.")
-		fmt.Println("It is not safe to apply additional migrations at this time.")
-		fmt.Println("For more information, and help resolving the issue, do `roamer status`.")
-		os.Exit(1)
+	if report.OK() {
+		return nil
 	}
 
-	isInOrder, err := environment.VerifyOrder()
-	if err != nil {
-		return err
+	dirty := report.IDs(roamer.ErrMigrationDirty)
+	if len(dirty) > 0 {
+		fmt.Printf("One or more migrations are marked as dirty: %s.\n", strings.Join(dirty, ", "))
+	}
+
+	missing := report.IDs(roamer.ErrMigrationMissing)
+	if len(missing) > 0 {
+		fmt.Printf("There are migrations in the database that do not exist on disk: %s.\n", strings.Join(missing, ", "))
 	}
 
-	if !isInOrder {
+	outOfOrder := report.IDs(roamer.ErrMigrationOutOfOrder)
+	if len(outOfOrder) > 0 {
 		fmt.Println("The migrations on disk do not match the order of migrations applied to the database.")
-		fmt.Println("It is not safe to apply additional migrations at this time.")
-		fmt.Println("For more information, and help resolving the issue, do `roamer status`.")
-		os.Exit(1)
+		fmt.Printf("These migrations were applied before ones that come earlier on disk: %s.\n", strings.Join(outOfOrder, ", "))
+	}
+
+	for _, problem := range report.Problems {
+		if problem.Kind == roamer.ErrMigrationParentNotApplied {
+			fmt.Printf("Migration %s was applied without its parent %s.\n", problem.ID, problem.RelatedID)
+		}
 	}
 
-	return nil
+	fmt.Println("It is not safe to apply additional migrations at this time.")
+	fmt.Println("For more information, and help resolving the issue, do `roamer status`.")
+	return exitError{exitCodeNotSafe}
 }
@@ -1,17 +1,28 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"github.com/thatoddmailbox/roamer"
 )
 
-func commandCreate(environment *roamer.Environment, options commandOptions, args []string) {
+func commandCreate(ctx context.Context, environment *roamer.Environment, options commandOptions, args []string) error {
 	description := args[0]
 	err := environment.CreateMigration(description)
 	if err != nil {
-		panic(err)
+		multipleHeadsErr, ok := err.(roamer.MultipleHeadsError)
+		if ok {
+			fmt.Printf("The revision graph has multiple heads: %s.\n", strings.Join(multipleHeadsErr.Heads, ", "))
+			fmt.Println("Merge them by doing `roamer merge <description>` before creating a new migration.")
+			return exitError{exitCodeNotSafe}
+		}
+
+		return err
 	}
 
 	fmt.Println("A new migration has been created.")
+
+	return nil
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+type doctorCheckOutput struct {
+	Name     string   `json:"name"`
+	Status   string   `json:"status"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+func doctorCheckStatus(check roamer.DoctorCheck) string {
+	if check.Skipped {
+		return "skipped"
+	}
+	if len(check.Problems) > 0 {
+		return "failed"
+	}
+
+	return "ok"
+}
+
+func commandDoctor(ctx context.Context, environment *roamer.Environment, options commandOptions, args []string) error {
+	checks := roamer.Doctor(ctx, options.environmentPath, options.localConfig, options.environmentOptions...)
+
+	ok := true
+	output := []doctorCheckOutput{}
+	for _, check := range checks {
+		checkOutput := doctorCheckOutput{
+			Name:   check.Name,
+			Status: doctorCheckStatus(check),
+		}
+		for _, problem := range check.Problems {
+			checkOutput.Problems = append(checkOutput.Problems, problem.Error())
+		}
+
+		if !check.OK() {
+			ok = false
+		}
+
+		output = append(output, checkOutput)
+	}
+
+	if options.format == "json" {
+		err := printJSON(output)
+		if err != nil {
+			return err
+		}
+	} else {
+		problemCount := 0
+		for _, checkOutput := range output {
+			fmt.Printf("%-8s %s\n", checkOutput.Status, checkOutput.Name)
+			for _, problem := range checkOutput.Problems {
+				fmt.Printf("         - %s\n", problem)
+			}
+
+			problemCount += len(checkOutput.Problems)
+		}
+
+		fmt.Println("")
+		if ok {
+			fmt.Println("No problems found.")
+		} else if problemCount == 1 {
+			fmt.Println("Found 1 problem.")
+		} else {
+			fmt.Printf("Found %d problems.\n", problemCount)
+		}
+	}
+
+	if !ok {
+		return exitError{exitCodeProblem}
+	}
+
+	return nil
+}
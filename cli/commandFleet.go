@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+type fleetResultOutput struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Migrations int     `json:"migrations"`
+	Duration   float64 `json:"duration"`
+	Error      string  `json:"error,omitempty"`
+}
+
+func fleetResultStatus(result roamer.FleetResult) string {
+	if result.Skipped {
+		return "skipped"
+	}
+	if result.Err != nil {
+		return "failed"
+	}
+
+	return "ok"
+}
+
+func commandFleet(ctx context.Context, environment *roamer.Environment, options commandOptions, args []string) error {
+	if args[0] != "upgrade" {
+		fmt.Printf("Unknown fleet action '%s'. The only fleet action is upgrade.\n", args[0])
+		return exitError{exitCodeUsage}
+	}
+
+	if options.targets == "" {
+		fmt.Println("The fleet command needs a file listing its targets. Pass it with -targets.")
+		return exitError{exitCodeUsage}
+	}
+
+	targetsFile, err := os.Open(options.targets)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Printf("Targets file '%s' does not exist.\n", options.targets)
+			return exitError{exitCodeUsage}
+		}
+
+		return err
+	}
+	targets, err := roamer.ReadFleetTargets(targetsFile, options.dsnTemplate)
+	targetsFile.Close()
+	if err != nil {
+		fmt.Printf("Could not read targets file '%s': %s\n", options.targets, err)
+		return exitError{exitCodeUsage}
+	}
+
+	if len(targets) == 0 {
+		fmt.Printf("Targets file '%s' does not list any targets.\n", options.targets)
+		return exitError{exitCodeUsage}
+	}
+
+	fleetOptions := roamer.FleetOptions{
+		Concurrency: options.concurrency,
+		FailFast:    options.failFast,
+		Timeout:     options.timeout,
+	}
+	if options.format == "text" {
+		fmt.Printf("Upgrading %d targets, %d at a time\n\n", len(targets), options.concurrency)
+
+		fleetOptions.TargetEnd = func(result roamer.FleetResult) {
+			switch fleetResultStatus(result) {
+			case "skipped":
+				fmt.Printf("skipped  %s\n", result.Target.Name)
+			case "failed":
+				fmt.Printf("FAILED   %s after %d migrations in %s: %s\n", result.Target.Name, result.Migrations, result.Duration.Round(time.Millisecond), result.Err)
+			default:
+				fmt.Printf("ok       %s (%d migrations in %s)\n", result.Target.Name, result.Migrations, result.Duration.Round(time.Millisecond))
+			}
+		}
+	}
+
+	results := environment.UpgradeFleet(ctx, targets, fleetOptions)
+
+	succeeded := 0
+	failed := 0
+	skipped := 0
+	output := []fleetResultOutput{}
+	for _, result := range results {
+		status := fleetResultStatus(result)
+		switch status {
+		case "skipped":
+			skipped++
+		case "failed":
+			failed++
+		default:
+			succeeded++
+		}
+
+		resultOutput := fleetResultOutput{
+			Name:       result.Target.Name,
+			Status:     status,
+			Migrations: result.Migrations,
+			Duration:   result.Duration.Seconds(),
+		}
+		if result.Err != nil {
+			resultOutput.Error = result.Err.Error()
+		}
+		output = append(output, resultOutput)
+	}
+
+	if options.format == "json" {
+		err := printJSON(output)
+		if err != nil {
+			return err
+		}
+	} else {
+		fmt.Println()
+		fmt.Printf("%d succeeded, %d failed, %d skipped.\n", succeeded, failed, skipped)
+	}
+
+	if failed > 0 || skipped > 0 {
+		return exitError{exitCodeProblem}
+	}
+
+	return nil
+}
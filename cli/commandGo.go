@@ -1,8 +1,9 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
@@ -10,62 +11,141 @@ import (
 	"github.com/thatoddmailbox/roamer"
 )
 
-func commandGo(environment *roamer.Environment, options commandOptions, args []string) {
-	err := requireSafe(environment)
+func printIndented(sql string) {
+	for _, line := range strings.Split(strings.TrimRight(sql, "\r\n"), "\n") {
+		fmt.Println("    " + line)
+	}
+}
+
+func printPlan(plan roamer.Plan) {
+	if plan.BeforeAll != "" {
+		fmt.Println("Would run beforeAll callback")
+		printIndented(plan.BeforeAll)
+		fmt.Println()
+	}
+
+	for _, plannedMigration := range plan.Migrations {
+		actionText := "apply"
+		if plannedMigration.Stamp {
+			actionText = "stamp"
+		}
+
+		fmt.Printf(
+			"Would %s %s migration %s - %s\n",
+			actionText,
+			plannedMigration.Direction.String(),
+			plannedMigration.Migration.ID,
+			plannedMigration.Migration.Description,
+		)
+
+		if plannedMigration.BeforeEach != "" {
+			printIndented(plannedMigration.BeforeEach)
+		}
+		for _, statement := range plannedMigration.PreStatements {
+			printIndented(statement)
+		}
+		if plannedMigration.Contents != "" {
+			printIndented(plannedMigration.Contents)
+		}
+		if plannedMigration.AfterEach != "" {
+			printIndented(plannedMigration.AfterEach)
+		}
+		for _, statement := range plannedMigration.PostStatements {
+			printIndented(statement)
+		}
+
+		fmt.Println()
+	}
+
+	if plan.AfterAll != "" {
+		fmt.Println("Would run afterAll callback")
+		printIndented(plan.AfterAll)
+		fmt.Println()
+	}
+}
+
+func printCurrentMigration(environment *roamer.Environment) error {
+	lastAppliedMigration, err := environment.GetLastAppliedMigrationContext(context.Background())
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	currentString := "[nothing]"
+	if lastAppliedMigration != nil {
+		currentString = lastAppliedMigration.ID
 	}
+	fmt.Printf("The database is now at migration %s.\n", currentString)
+
+	return nil
+}
 
-	lastAppliedMigration, err := environment.GetLastAppliedMigration()
+func commandGo(ctx context.Context, environment *roamer.Environment, options commandOptions, args []string) error {
+	err := requireSafe(ctx, environment)
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	lastAppliedMigration, err := environment.GetLastAppliedMigrationContext(ctx)
+	if err != nil {
+		return err
 	}
 
 	var lastMigration *roamer.Migration
 	if lastAppliedMigration != nil {
-		lastMigration, err = environment.ResolveIDOrOffset(lastAppliedMigration.ID)
+		lastMigration, err = environment.ResolveIDOrOffsetContext(ctx, lastAppliedMigration.ID)
 		if err != nil {
 			if err == roamer.ErrMigrationNotFound {
 				fmt.Printf("Last applied migration %s does not exist.\nDo `roamer status` for help resolving this.\n", lastAppliedMigration.ID)
-				os.Exit(1)
-				return
+				return exitError{exitCodeNotSafe}
 			} else {
-				panic(err)
+				return err
 			}
 		}
 	}
 
-	targetMigration, err := environment.ResolveIDOrOffset(args[0])
+	targetMigration, err := environment.ResolveIDOrOffsetContext(ctx, args[0])
 	if err != nil {
 		if err == roamer.ErrMigrationNotFound {
 			fmt.Printf("Migration %s does not exist.\n", args[0])
-			os.Exit(1)
-			return
+			return exitError{exitCodeUsage}
+		} else if err == roamer.ErrAmbiguousOffset {
+			fmt.Printf("Offset %s is ambiguous, because the revision graph branches there. Use a migration ID instead.\n", args[0])
+			return exitError{exitCodeUsage}
 		} else {
-			panic(err)
+			return err
 		}
 	}
 
-	if lastMigration != nil && targetMigration != nil {
+	allowOutOfOrder := environment.AllowsOutOfOrder()
+
+	if lastMigration != nil && targetMigration != nil && !allowOutOfOrder {
 		if lastMigration.ID == targetMigration.ID {
 			fmt.Printf("The database is already at migration %s.\n", targetMigration.ID)
-			os.Exit(1)
-			return
+			return exitError{exitCodeProblem}
 		}
 	}
 
 	if lastMigration == nil && targetMigration == nil {
 		fmt.Println("The database is already at no migrations.")
-		os.Exit(1)
-		return
+		return exitError{exitCodeProblem}
 	}
 
 	operation, err := environment.NewOperation(lastMigration, targetMigration)
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	if allowOutOfOrder && operation.Distance == 0 {
+		if targetMigration == nil {
+			fmt.Println("The database is already at no migrations.")
+		} else {
+			fmt.Printf("The database already has every migration up to %s applied.\n", targetMigration.ID)
+		}
+		return exitError{exitCodeProblem}
 	}
 
 	operation.Stamp = options.stamp
+	operation.Timeout = options.timeout
 
 	fromString := "[nothing]"
 	if lastMigration != nil {
@@ -79,6 +159,25 @@ func commandGo(environment *roamer.Environment, options commandOptions, args []s
 	if options.stamp {
 		details = " (stamping only)"
 	}
+
+	if options.dryRun {
+		plan, err := operation.PlanContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		if options.dryRunFormat == "sql" {
+			fmt.Printf("-- roamer: going %s -> %s (%s)%s\n\n", fromString, toString, operation.DistanceString(), details)
+			fmt.Print(plan.SQL())
+			return nil
+		}
+
+		fmt.Printf("Going %s -> %s (%s)%s (dry run)\n\n", fromString, toString, operation.DistanceString(), details)
+		printPlan(plan)
+		fmt.Println("This was a dry run. No changes have been made.")
+		return nil
+	}
+
 	fmt.Printf("Going %s -> %s (%s)%s\n\n", fromString, toString, operation.DistanceString(), details)
 
 	if operation.Direction == roamer.DirectionDown {
@@ -88,15 +187,14 @@ func commandGo(environment *roamer.Environment, options commandOptions, args []s
 				Message: "You're about to run one or more down migrations, which can result in data loss. Continue?",
 			}, &answer)
 			if err != nil {
-				panic(err)
+				return err
 			}
 
 			fmt.Println()
 
 			if !answer {
 				fmt.Println("Migration cancelled. No changes have been made.")
-				os.Exit(1)
-				return
+				return exitError{exitCodeInterrupted}
 			}
 		}
 	}
@@ -106,13 +204,74 @@ func commandGo(environment *roamer.Environment, options commandOptions, args []s
 		actionText = "Stamping"
 	}
 
-	operation.PreMigrationCallback = func(m *roamer.Migration, d roamer.Direction) {
-		fmt.Printf("%s %s migration %s - %s\n", actionText, d.String(), m.ID, m.Description)
+	operation.Hooks = roamer.OperationHooks{
+		MigrationStart: func(m *roamer.Migration, d roamer.Direction) {
+			fmt.Printf("%s %s migration %s - %s\n", actionText, d.String(), m.ID, m.Description)
+		},
 	}
 
-	err = operation.Run()
+	err = operation.RunContext(ctx)
 	if err != nil {
 		operationErr, isOperationErr := err.(roamer.OperationError)
+		if ctx.Err() != nil {
+			fmt.Println()
+			if isOperationErr {
+				fmt.Printf(
+					"Interrupted while %s migration %s.\n",
+					strings.ToLower(actionText),
+					operationErr.Migration.ID,
+				)
+				fmt.Println("The database may now be in an inconsistent state. The migration has been marked as dirty.")
+				fmt.Println("You must connect to the database and manually resolve the issue.")
+				fmt.Println("Then, update the " + environment.GetHistoryTableName() + " table and, depending on how you resolved the issue, either delete the migration or set the dirty flag to 0.")
+				return exitError{exitCodeInterrupted}
+			}
+
+			fmt.Println("Interrupted. No further migrations have been applied.")
+			err = printCurrentMigration(environment)
+			if err != nil {
+				return err
+			}
+			return exitError{exitCodeInterrupted}
+		}
+
+		callbackErr, isCallbackErr := err.(roamer.CallbackError)
+		if isCallbackErr {
+			fmt.Printf("There was an error running the %s callback (%s)!\n", callbackErr.Type, callbackErr.Filename)
+			fmt.Println()
+			fmt.Println(callbackErr.Inner)
+			fmt.Println()
+			err = printCurrentMigration(environment)
+			if err != nil {
+				return err
+			}
+			return exitError{exitCodeMigration}
+		}
+
+		// a timeout that stopped a migration is wrapped in the OperationError for it, but it's still running out of time
+		var timeoutErr roamer.TimeoutError
+		if errors.As(err, &timeoutErr) {
+			fmt.Println()
+			if isOperationErr {
+				if timeoutErr.Operation {
+					fmt.Printf("The operation ran for longer than %s while %s migration %s.\n", timeoutErr.Timeout, strings.ToLower(actionText), operationErr.Migration.ID)
+				} else {
+					fmt.Printf("Migration %s ran for longer than its timeout of %s.\n", operationErr.Migration.ID, timeoutErr.Timeout)
+				}
+				fmt.Println("The database may now be in an inconsistent state. The migration has been marked as dirty.")
+				fmt.Println("You must connect to the database and manually resolve the issue.")
+				fmt.Println("Then, update the " + environment.GetHistoryTableName() + " table and, depending on how you resolved the issue, either delete the migration or set the dirty flag to 0.")
+				return exitError{exitCodeInterrupted}
+			}
+
+			fmt.Printf("The operation ran for longer than %s. No further migrations have been applied.\n", timeoutErr.Timeout)
+			err = printCurrentMigration(environment)
+			if err != nil {
+				return err
+			}
+			return exitError{exitCodeInterrupted}
+		}
+
 		if isOperationErr {
 			fmt.Printf(
 				"There was an error %s migration %s!\n",
@@ -125,11 +284,13 @@ func commandGo(environment *roamer.Environment, options commandOptions, args []s
 			fmt.Println("The database may now be in an inconsistent state. The migration has been marked as dirty.")
 			fmt.Println("You must connect to the database and manually resolve the issue.")
 			fmt.Println("Then, update the " + environment.GetHistoryTableName() + " table and, depending on how you resolved the issue, either delete the migration or set the dirty flag to 0.")
-			os.Exit(1)
+			return exitError{exitCodeMigration}
 		}
 
-		panic(err)
+		return err
 	}
 
 	fmt.Printf("\nThe database is now at migration %s.\n", toString)
+
+	return nil
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
@@ -22,7 +23,7 @@ func writeTOMLToFile(filePath string, perm os.FileMode, thing interface{}) error
 	return encoder.Encode(thing)
 }
 
-func commandInit(environment *roamer.Environment, options commandOptions, args []string) {
+func commandInit(ctx context.Context, environment *roamer.Environment, options commandOptions, args []string) error {
 	// find the default configs
 	config := roamer.DefaultConfig
 	localConfig := roamer.DefaultLocalConfig
@@ -38,44 +39,46 @@ func commandInit(environment *roamer.Environment, options commandOptions, args [
 	if err == nil {
 		fmt.Println("A roamer.toml file already exists!")
 		fmt.Println("Perhaps you meant `roamer setup`?")
-		os.Exit(1)
+		return exitError{exitCodeProblem}
 	}
 	if !os.IsNotExist(err) {
-		panic(err)
+		return err
 	}
 	_, err = os.Stat(localConfigPath)
 	if err == nil {
 		fmt.Println("A roamer.local.toml file already exists!")
 		fmt.Println("It looks like you already have a roamer environment set up.")
-		os.Exit(1)
+		return exitError{exitCodeProblem}
 	}
 	if !os.IsNotExist(err) {
-		panic(err)
+		return err
 	}
 	_, err = os.Stat(migrationsPath)
 	if err == nil {
 		fmt.Println("A migrations directory already exists!")
 		fmt.Println("You need to remove or move this directory first.")
-		os.Exit(1)
+		return exitError{exitCodeProblem}
 	}
 	if !os.IsNotExist(err) {
-		panic(err)
+		return err
 	}
 
 	// now actually create the things
 	err = writeTOMLToFile(configPath, 0775, config)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	err = writeTOMLToFile(localConfigPath, 0700, localConfig)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	err = os.Mkdir(migrationsPath, 0775)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	fmt.Println("A roamer.toml, roamer.local.toml, and migrations directory have been created for you.")
 	fmt.Println("If you're using version control software, make sure to exclude roamer.local.toml!")
+
+	return nil
 }
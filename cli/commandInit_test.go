@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+// readTOMLWrittenFor returns what writeTOMLToFile writes for the given thing.
+func readTOMLWrittenFor(t *testing.T, thing interface{}) string {
+	t.Helper()
+
+	filePath := filepath.Join(t.TempDir(), "config.toml")
+	err := writeTOMLToFile(filePath, 0600, thing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestWriteDefaultLocalConfig(t *testing.T) {
+	// options that aren't set are left out, so that the file only has what people need to fill in
+	expected := "[Database]\nDriver = \"mysql\"\nDSN = \"user:password@tcp(localhost:3306)/dbname\"\n"
+
+	result := readTOMLWrittenFor(t, roamer.DefaultLocalConfig)
+	if result != expected {
+		t.Errorf("got\n%s\nexpected\n%s", result, expected)
+	}
+}
+
+func TestWriteDefaultConfig(t *testing.T) {
+	config := roamer.DefaultConfig
+	config.Environment.MinimumVersion = "1.0.0"
+
+	expected := `[Environment]
+MigrationDirectory = "migrations/"
+MinimumVersion = "1.0.0"
+
+[Callbacks]
+BeforeAll = "beforeAll"
+BeforeEach = "beforeEach"
+AfterEach = "afterEach"
+AfterAll = "afterAll"
+`
+
+	result := readTOMLWrittenFor(t, config)
+	if result != expected {
+		t.Errorf("got\n%s\nexpected\n%s", result, expected)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+type lintProblemOutput struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Message  string `json:"message"`
+}
+
+// lintFilePath returns the path of the given migration file relative to the working directory, which is what CI
+// systems expect in annotations. If it can't be made relative, the full path is used instead.
+func lintFilePath(environment *roamer.Environment, filename string) string {
+	filePath := filepath.Join(environment.MigrationDirectoryPath(), filename)
+
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		return filePath
+	}
+
+	absolutePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return filePath
+	}
+
+	relativePath, err := filepath.Rel(workingDirectory, absolutePath)
+	if err != nil {
+		return filePath
+	}
+
+	return filepath.ToSlash(relativePath)
+}
+
+// pluralize returns the given count followed by the given noun, which is made plural unless the count is 1.
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+func commandLint(ctx context.Context, environment *roamer.Environment, options commandOptions, args []string) error {
+	problems, err := environment.Lint()
+	if err != nil {
+		return err
+	}
+
+	errorCount := 0
+	warningCount := 0
+	output := []lintProblemOutput{}
+	for _, problem := range problems {
+		if problem.Severity == roamer.LintSeverityError {
+			errorCount++
+		} else {
+			warningCount++
+		}
+
+		output = append(output, lintProblemOutput{
+			Rule:     problem.Rule,
+			Severity: string(problem.Severity),
+			File:     lintFilePath(environment, problem.Filename),
+			Line:     problem.Line,
+			Message:  problem.Message,
+		})
+	}
+
+	if options.format == "json" {
+		err = printJSON(output)
+		if err != nil {
+			return err
+		}
+	} else if options.format == "github" {
+		// these are workflow commands, which GitHub Actions turns into annotations on the lines of the pull request
+		for _, problemOutput := range output {
+			fmt.Printf("::%s file=%s,line=%d,title=%s::%s\n", problemOutput.Severity, problemOutput.File, problemOutput.Line, problemOutput.Rule, problemOutput.Message)
+		}
+	} else {
+		// this is the same format as compilers use, which most editors and CI systems can pick up
+		for _, problemOutput := range output {
+			fmt.Printf("%s:%d: %s: %s [%s]\n", problemOutput.File, problemOutput.Line, problemOutput.Severity, problemOutput.Message, problemOutput.Rule)
+		}
+
+		if len(output) == 0 {
+			fmt.Println("No problems found.")
+		} else {
+			fmt.Printf("\nFound %s and %s.\n", pluralize(errorCount, "error"), pluralize(warningCount, "warning"))
+		}
+	}
+
+	// warnings are worth a look, but shouldn't fail the build
+	if errorCount > 0 {
+		return exitError{exitCodeProblem}
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+func commandMerge(ctx context.Context, environment *roamer.Environment, options commandOptions, args []string) error {
+	heads := headIDs(environment)
+
+	description := args[0]
+	err := environment.CreateMergeMigration(description)
+	if err != nil {
+		if err == roamer.ErrNothingToMerge {
+			fmt.Println("There is only one head, so there is nothing to merge.")
+			return exitError{exitCodeProblem}
+		}
+
+		return err
+	}
+
+	fmt.Printf("A new migration has been created, merging %s.\n", strings.Join(heads, ", "))
+
+	return nil
+}
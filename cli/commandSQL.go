@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+func commandSQL(ctx context.Context, environment *roamer.Environment, options commandOptions, args []string) error {
+	fromMigration, err := environment.ResolveIDOrOffsetContext(ctx, args[0])
+	if err != nil {
+		if err == roamer.ErrMigrationNotFound {
+			fmt.Fprintf(os.Stderr, "Migration %s does not exist.\n", args[0])
+			return exitError{exitCodeUsage}
+		} else if err == roamer.ErrEnvironmentOffline {
+			fmt.Fprintln(os.Stderr, "Relative offsets cannot be used with the sql command, since it does not connect to the database.")
+			return exitError{exitCodeUsage}
+		} else {
+			return err
+		}
+	}
+
+	targetMigration, err := environment.ResolveIDOrOffsetContext(ctx, args[1])
+	if err != nil {
+		if err == roamer.ErrMigrationNotFound {
+			fmt.Fprintf(os.Stderr, "Migration %s does not exist.\n", args[1])
+			return exitError{exitCodeUsage}
+		} else if err == roamer.ErrEnvironmentOffline {
+			fmt.Fprintln(os.Stderr, "Relative offsets cannot be used with the sql command, since it does not connect to the database.")
+			return exitError{exitCodeUsage}
+		} else {
+			return err
+		}
+	}
+
+	if fromMigration == nil && targetMigration == nil {
+		fmt.Fprintln(os.Stderr, "The start and end of the script are both at no migrations.")
+		return exitError{exitCodeUsage}
+	}
+	if fromMigration != nil && targetMigration != nil && fromMigration.ID == targetMigration.ID {
+		fmt.Fprintf(os.Stderr, "The start and end of the script are both at migration %s.\n", targetMigration.ID)
+		return exitError{exitCodeUsage}
+	}
+
+	operation, err := environment.NewOperation(fromMigration, targetMigration)
+	if err != nil {
+		return err
+	}
+
+	operation.Stamp = options.stamp
+
+	plan, err := operation.PlanContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	fromString := "[nothing]"
+	if fromMigration != nil {
+		fromString = fromMigration.ID
+	}
+	toString := "[nothing]"
+	if targetMigration != nil {
+		toString = targetMigration.ID
+	}
+	details := ""
+	if options.stamp {
+		details = " (stamping only)"
+	}
+
+	fmt.Printf("-- roamer: going %s -> %s (%s)%s\n", fromString, toString, operation.DistanceString(), details)
+	fmt.Printf("-- This script must be run against a database that is at migration %s.\n\n", fromString)
+	fmt.Print(plan.SQL())
+
+	return nil
+}
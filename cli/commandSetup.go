@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
@@ -8,7 +9,7 @@ import (
 	"github.com/thatoddmailbox/roamer"
 )
 
-func commandSetup(environment *roamer.Environment, options commandOptions, args []string) {
+func commandSetup(ctx context.Context, environment *roamer.Environment, options commandOptions, args []string) error {
 	// find the default configs
 	localConfig := roamer.DefaultLocalConfig
 
@@ -22,18 +23,20 @@ func commandSetup(environment *roamer.Environment, options commandOptions, args
 	if err == nil {
 		fmt.Println("A " + localConfigFile + " file already exists!")
 		fmt.Println("This normally means you already have a roamer environment set up.")
-		os.Exit(1)
+		return exitError{exitCodeProblem}
 	}
 	if !os.IsNotExist(err) {
-		panic(err)
+		return err
 	}
 
 	// now actually create the thing
 	err = writeTOMLToFile(localConfigPath, 0600, localConfig)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	fmt.Println("A " + localConfigFile + " file has been created for you.")
 	fmt.Println("You should edit it to include your database connection details.")
+
+	return nil
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strconv"
@@ -17,33 +19,182 @@ func spacing(str string, wantLen int) string {
 	return strings.Repeat(" ", wantLen-len(str)) + str
 }
 
-func commandStatus(environment *roamer.Environment, options commandOptions, args []string) {
-	allMigrations, err := environment.ListAllMigrations()
+type statusState string
+
+const (
+	statusStateApplied statusState = "applied"
+	statusStateDirty   statusState = "dirty"
+	statusStateMissing statusState = "missing"
+	statusStatePending statusState = "pending"
+)
+
+type statusEntry struct {
+	Offset      *int        `json:"offset"`
+	ID          string      `json:"id"`
+	Description string      `json:"description"`
+	State       statusState `json:"state"`
+	Dirty       bool        `json:"dirty"`
+	OutOfOrder  bool        `json:"outOfOrder"`
+	Skipped     bool        `json:"skipped"`
+	AppliedAt   *int        `json:"appliedAt"`
+}
+
+type statusSummary struct {
+	Total        int     `json:"total"`
+	Applied      int     `json:"applied"`
+	Pending      int     `json:"pending"`
+	Dirty        int     `json:"dirty"`
+	Missing      int     `json:"missing"`
+	LastApplied  *string `json:"lastApplied"`
+	OrderMatches bool    `json:"orderMatches"`
+
+	// Heads are the heads of the revision graph, if the migrations form one.
+	Heads []string `json:"heads,omitempty"`
+}
+
+type statusOutput struct {
+	Migrations []statusEntry `json:"migrations"`
+	Summary    statusSummary `json:"summary"`
+}
+
+// getStatusEntries lists the applied migrations, in the order they appear in the history table, followed by the pending migrations.
+// If the order of the history doesn't match the migrations on disk, or the environment allows out-of-order migrations,
+// it instead lists every migration in the order they appear on disk, like the text output does.
+func getStatusEntries(environment *roamer.Environment, allMigrations []roamer.Migration, appliedMigrations []roamer.AppliedMigration, orderMatches bool) []statusEntry {
+	if !orderMatches || environment.AllowsOutOfOrder() {
+		return getOutOfOrderStatusEntries(allMigrations, appliedMigrations, environment.AllowsOutOfOrder())
+	}
+
+	entries := []statusEntry{}
+
+	i := 0
+	for _, appliedMigration := range appliedMigrations {
+		appliedAt := appliedMigration.AppliedAt
+		offset := i + 1
+		entry := statusEntry{
+			Offset:    &offset,
+			ID:        appliedMigration.ID,
+			State:     statusStateApplied,
+			Dirty:     appliedMigration.Dirty,
+			AppliedAt: &appliedAt,
+		}
+
+		if appliedMigration.Dirty {
+			entry.State = statusStateDirty
+		}
+
+		migration, err := environment.GetMigrationByID(appliedMigration.ID)
+		if err == nil {
+			entry.Description = migration.Description
+		} else {
+			// the only error is ErrMigrationNotFound
+			entry.State = statusStateMissing
+		}
+
+		entries = append(entries, entry)
+		i += 1
+	}
+
+	unappliedMigrations := allMigrations
+	if i <= len(allMigrations) {
+		unappliedMigrations = allMigrations[i:]
+	}
+
+	for j, unappliedMigration := range unappliedMigrations {
+		offset := i + 1 + j
+		entries = append(entries, statusEntry{
+			Offset:      &offset,
+			ID:          unappliedMigration.ID,
+			Description: unappliedMigration.Description,
+			State:       statusStatePending,
+		})
+	}
+
+	return entries
+}
+
+// getOutOfOrderStatusEntries lists every migration in the order they appear on disk, with migrations that are only in the history table mixed in by ID.
+// Unless the environment allows out-of-order migrations, pending migrations that come before an applied one are marked as skipped.
+func getOutOfOrderStatusEntries(allMigrations []roamer.Migration, appliedMigrations []roamer.AppliedMigration, allowOutOfOrder bool) []statusEntry {
+	appliedAtByID := map[string]int{}
+	for _, appliedMigration := range appliedMigrations {
+		appliedAtByID[appliedMigration.ID] = appliedMigration.AppliedAt
+	}
+
+	entries := []statusEntry{}
+	for _, row := range getMergedStatusRows(allMigrations, appliedMigrations) {
+		entry := statusEntry{
+			ID:          row.id,
+			Description: row.description,
+			State:       statusStateApplied,
+			Dirty:       row.dirty,
+			OutOfOrder:  row.outOfOrder,
+			Skipped:     row.skipped && !allowOutOfOrder,
+		}
+
+		if row.diskPosition != 0 {
+			offset := row.diskPosition
+			entry.Offset = &offset
+		}
+
+		if row.historyPosition != 0 {
+			appliedAt := appliedAtByID[row.id]
+			entry.AppliedAt = &appliedAt
+		} else {
+			entry.State = statusStatePending
+		}
+
+		if row.dirty {
+			entry.State = statusStateDirty
+		}
+		if row.missing {
+			entry.State = statusStateMissing
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func commandStatus(ctx context.Context, environment *roamer.Environment, options commandOptions, args []string) error {
+	ok, err := printStatus(ctx, environment, options)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	appliedMigrations, err := environment.ListAppliedMigrations()
+	if !ok {
+		return exitError{exitCodeProblem}
+	}
+
+	return nil
+}
+
+// printStatus prints the status of the environment in the format given by the options.
+// It returns false if there is a problem with the environment that should result in a non-zero exit code.
+func printStatus(ctx context.Context, environment *roamer.Environment, options commandOptions) (bool, error) {
+	if options.format == "json" {
+		output, ok, err := getStatusOutput(ctx, environment)
+		if err != nil {
+			return false, err
+		}
+
+		return ok, printJSON(output)
+	}
+
+	allMigrations, appliedMigrations, orderMatches, err := loadStatus(ctx, environment)
 	if err != nil {
-		panic(err)
+		return false, err
 	}
 
 	if len(allMigrations) == 0 {
 		fmt.Println("There are no migrations.")
 		fmt.Println("Get started by doing `roamer create <description>`")
-		os.Exit(1)
-		return
+		return false, nil
 	}
 
-	orderMatches, err := environment.VerifyOrder()
-	if err != nil {
-		panic(err)
-	}
-	if !orderMatches {
-		fmt.Println("The migrations on disk do not match the order of migrations applied to the database.")
-		fmt.Println("The status command is currently unable to provide useful output in this scenario.")
-		fmt.Println("You should check the " + environment.GetHistoryTableName() + " table and compare it to the migrations on disk.")
-		os.Exit(1)
+	if !orderMatches || environment.AllowsOutOfOrder() {
+		return printMergedStatus(environment, allMigrations, appliedMigrations, orderMatches), nil
 	}
 
 	maxIDLen := 0
@@ -70,39 +221,25 @@ func commandStatus(environment *roamer.Environment, options commandOptions, args
 	haveDirty := false
 	haveMissing := false
 
-	i := 0
-	for _, appliedMigration := range appliedMigrations {
-		idDisplay := appliedMigration.ID + " "
-		if appliedMigration.Dirty {
+	for _, entry := range getStatusEntries(environment, allMigrations, appliedMigrations, orderMatches) {
+		offsetDisplay := spacing("@"+strconv.Itoa(*entry.Offset)+" ", offsetColumnLength)
+
+		idDisplay := entry.ID + " "
+		if entry.State == statusStatePending {
+			idDisplay = "*" + entry.ID
+		}
+		if entry.Dirty {
 			haveDirty = true
-			idDisplay = "!" + appliedMigration.ID
+			idDisplay = "!" + entry.ID
 		}
 
-		offsetDisplay := spacing("@"+strconv.Itoa(i+1)+" ", offsetColumnLength)
-
-		migration, err := environment.GetMigrationByID(appliedMigration.ID)
-		if err == nil {
-			fmt.Println(offsetDisplay + " " + idDisplay + columnSpacingStr + migration.Description)
-		} else {
-			if err == roamer.ErrMigrationNotFound {
-				fmt.Println(offsetDisplay + " " + idDisplay + columnSpacingStr + "*** ERROR: missing corresponding migration file!")
-				haveMissing = true
-			} else {
-				panic(err)
-			}
+		description := entry.Description
+		if entry.State == statusStateMissing {
+			description = "*** ERROR: missing corresponding migration file!"
+			haveMissing = true
 		}
 
-		i += 1
-	}
-
-	unappliedMigrations := allMigrations
-	if i <= len(allMigrations) {
-		unappliedMigrations = allMigrations[i:]
-	}
-
-	for j, unappliedMigration := range unappliedMigrations {
-		offsetDisplay := spacing("@"+strconv.Itoa(i+1+j)+" ", offsetColumnLength)
-		fmt.Println(offsetDisplay + " *" + unappliedMigration.ID + columnSpacingStr + unappliedMigration.Description)
+		fmt.Println(offsetDisplay + " " + idDisplay + columnSpacingStr + description)
 	}
 
 	if len(allMigrations) != len(appliedMigrations) {
@@ -124,7 +261,82 @@ func commandStatus(environment *roamer.Environment, options commandOptions, args
 		fmt.Println("You should restore these files, or, if you know what you're doing, remove the migration entries from the " + environment.GetHistoryTableName() + " table.")
 	}
 
-	if haveDirty || haveMissing {
-		os.Exit(1)
+	// like the JSON output, this fails on multiple heads, since nothing more can be applied until they're merged
+	heads := headIDs(environment)
+	if len(heads) > 1 {
+		fmt.Println()
+		fmt.Printf("The revision graph has multiple heads (%s). Merge them by doing `roamer merge <description>`.\n", strings.Join(heads, ", "))
+	}
+
+	return !haveDirty && !haveMissing && len(heads) < 2, nil
+}
+
+// loadStatus reads the migrations on disk and in the history table, and whether their order matches.
+func loadStatus(ctx context.Context, environment *roamer.Environment) ([]roamer.Migration, []roamer.AppliedMigration, bool, error) {
+	allMigrations, err := environment.ListAllMigrations()
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	appliedMigrations, err := environment.ListAppliedMigrationsContext(ctx)
+	if err != nil {
+		return nil, nil, false, err
 	}
+
+	orderReport, err := environment.VerifyOrderContext(ctx)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	return allMigrations, appliedMigrations, orderReport.OK(), nil
+}
+
+// getStatusOutput returns the status for the JSON output, along with whether it has the same problems that make the text output fail.
+func getStatusOutput(ctx context.Context, environment *roamer.Environment) (statusOutput, bool, error) {
+	allMigrations, appliedMigrations, orderMatches, err := loadStatus(ctx, environment)
+	if err != nil {
+		return statusOutput{}, false, err
+	}
+
+	output := statusOutput{
+		Migrations: getStatusEntries(environment, allMigrations, appliedMigrations, orderMatches),
+		Summary: statusSummary{
+			Total:        len(allMigrations),
+			Applied:      len(appliedMigrations),
+			OrderMatches: orderMatches,
+			Heads:        headIDs(environment),
+		},
+	}
+
+	for _, entry := range output.Migrations {
+		if entry.Dirty {
+			output.Summary.Dirty++
+		}
+
+		switch entry.State {
+		case statusStateMissing:
+			output.Summary.Missing++
+		case statusStatePending:
+			output.Summary.Pending++
+		}
+	}
+
+	lastAppliedMigration, err := environment.GetLastAppliedMigrationContext(ctx)
+	if err != nil {
+		return statusOutput{}, false, err
+	}
+	if lastAppliedMigration != nil {
+		output.Summary.LastApplied = &lastAppliedMigration.ID
+	}
+
+	ok := len(allMigrations) != 0 && orderMatches && len(output.Summary.Heads) < 2 && output.Summary.Dirty == 0 && output.Summary.Missing == 0
+
+	return output, ok, nil
+}
+
+// printJSON prints the given value as indented JSON.
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(v)
 }
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+type mergedStatusRow struct {
+	diskPosition    int
+	historyPosition int
+	id              string
+	description     string
+	dirty           bool
+
+	missing    bool
+	skipped    bool
+	outOfOrder bool
+}
+
+// getMergedStatusRows lists every migration that is either on disk or in the history table.
+// Disk positions are offsets into the migrations on disk, and history positions are the order that migrations were applied in.
+// Both start at 1, with 0 meaning that the migration is not there.
+func getMergedStatusRows(allMigrations []roamer.Migration, appliedMigrations []roamer.AppliedMigration) []mergedStatusRow {
+	// figure out the order that migrations were applied in
+	historyOrder := make([]roamer.AppliedMigration, len(appliedMigrations))
+	copy(historyOrder, appliedMigrations)
+	sort.SliceStable(historyOrder, func(i, j int) bool {
+		if historyOrder[i].AppliedAt != historyOrder[j].AppliedAt {
+			return historyOrder[i].AppliedAt < historyOrder[j].AppliedAt
+		}
+		return historyOrder[i].ID < historyOrder[j].ID
+	})
+
+	historyPositions := map[string]int{}
+	appliedByID := map[string]roamer.AppliedMigration{}
+	for i, appliedMigration := range historyOrder {
+		historyPositions[appliedMigration.ID] = i + 1
+		appliedByID[appliedMigration.ID] = appliedMigration
+	}
+
+	rows := []mergedStatusRow{}
+	onDisk := map[string]bool{}
+	for i, migration := range allMigrations {
+		onDisk[migration.ID] = true
+		rows = append(rows, mergedStatusRow{
+			diskPosition:    i + 1,
+			historyPosition: historyPositions[migration.ID],
+			id:              migration.ID,
+			description:     migration.Description,
+			dirty:           appliedByID[migration.ID].Dirty,
+		})
+	}
+
+	// add the migrations that are only in the history, next to where their ID would be on disk
+	for _, appliedMigration := range appliedMigrations {
+		if onDisk[appliedMigration.ID] {
+			continue
+		}
+
+		row := mergedStatusRow{
+			historyPosition: historyPositions[appliedMigration.ID],
+			id:              appliedMigration.ID,
+			dirty:           appliedMigration.Dirty,
+			missing:         true,
+		}
+
+		insertAt := sort.Search(len(rows), func(i int) bool {
+			return rows[i].id > appliedMigration.ID
+		})
+		rows = append(rows, mergedStatusRow{})
+		copy(rows[insertAt+1:], rows[insertAt:])
+		rows[insertAt] = row
+	}
+
+	for i := range rows {
+		if rows[i].missing {
+			continue
+		}
+
+		for j := i + 1; j < len(rows); j++ {
+			if rows[j].missing || rows[j].historyPosition == 0 {
+				continue
+			}
+
+			// a later migration on disk was applied, so this one was skipped
+			if rows[i].historyPosition == 0 {
+				rows[i].skipped = true
+				break
+			}
+
+			// a later migration on disk was applied before this one
+			if rows[j].historyPosition < rows[i].historyPosition {
+				rows[i].outOfOrder = true
+				break
+			}
+		}
+	}
+
+	return rows
+}
+
+// printMergedStatus prints the status when the order of migrations on disk doesn't match the history table,
+// showing each migration's position on disk next to its position in the history.
+// This is also used when the environment allows out-of-order migrations, in which case skipped migrations are just pending.
+// It returns false if there is anything to reconcile.
+func printMergedStatus(environment *roamer.Environment, allMigrations []roamer.Migration, appliedMigrations []roamer.AppliedMigration, orderMatches bool) bool {
+	allowOutOfOrder := environment.AllowsOutOfOrder()
+	rows := getMergedStatusRows(allMigrations, appliedMigrations)
+	heads := headIDs(environment)
+
+	maxIDLen := 2
+	for _, row := range rows {
+		if len(row.id) > maxIDLen {
+			maxIDLen = len(row.id)
+		}
+	}
+
+	columnSpacingStr := "    "
+	diskColumnLength := len(strconv.Itoa(len(allMigrations))) + 2
+	if diskColumnLength < len("Disk") {
+		diskColumnLength = len("Disk")
+	}
+	historyColumnLength := len(strconv.Itoa(len(appliedMigrations))) + 2
+	if historyColumnLength < len("History") {
+		historyColumnLength = len("History")
+	}
+
+	if !allowOutOfOrder {
+		fmt.Println("The migrations on disk do not match the order of migrations applied to the database.")
+		fmt.Println()
+	}
+	fmt.Println(
+		spacing("Disk", diskColumnLength) + columnSpacingStr +
+			spacing("History", historyColumnLength) + columnSpacingStr +
+			" ID" + strings.Repeat(" ", maxIDLen-2) + columnSpacingStr + "Description",
+	)
+
+	haveDirty := false
+	haveMissing := false
+	havePending := false
+	skippedRows := []int{}
+	for i, row := range rows {
+		diskDisplay := "-"
+		if row.diskPosition != 0 {
+			diskDisplay = "@" + strconv.Itoa(row.diskPosition)
+		}
+		historyDisplay := "-"
+		if row.historyPosition != 0 {
+			historyDisplay = "#" + strconv.Itoa(row.historyPosition)
+		}
+
+		marker := " "
+		if row.historyPosition == 0 {
+			marker = "*"
+			havePending = true
+		}
+		if row.dirty {
+			marker = "!"
+			haveDirty = true
+		}
+
+		description := row.description
+		if row.missing {
+			description = "*** ERROR: missing corresponding migration file!"
+			haveMissing = true
+		}
+		if row.skipped && !allowOutOfOrder {
+			description += "    <-- skipped"
+			skippedRows = append(skippedRows, i)
+		}
+		if row.outOfOrder {
+			description += "    <-- applied out of order"
+		}
+
+		fmt.Println(
+			spacing(diskDisplay, diskColumnLength) + columnSpacingStr +
+				spacing(historyDisplay, historyColumnLength) + columnSpacingStr +
+				marker + row.id + strings.Repeat(" ", maxIDLen-len(row.id)) + columnSpacingStr + description,
+		)
+	}
+
+	fmt.Println()
+	fmt.Println("(@ = position on disk, # = position in the " + environment.GetHistoryTableName() + " table, by when it was applied)")
+	if havePending {
+		fmt.Println("(* = migration has not been applied)")
+	}
+	if haveDirty {
+		fmt.Println("(! = migration is dirty)")
+	}
+
+	if allowOutOfOrder && orderMatches && len(heads) < 2 && !haveMissing && !haveDirty {
+		// pending migrations will be applied by the next upgrade, so there's nothing to reconcile
+		return true
+	}
+
+	fmt.Println()
+	fmt.Println("To reconcile the database with the migrations on disk:")
+
+	// the suggested commands have to work on the same stream and database as this one
+	flags := selectionFlags(environment)
+
+	for _, i := range skippedRows {
+		previousID := "@0"
+		for j := i - 1; j >= 0; j-- {
+			if !rows[j].missing {
+				previousID = rows[j].id
+				break
+			}
+		}
+
+		fmt.Printf("  * Migration %s was skipped. To apply it, run the output of `roamer %ssql %s %s` against the database.\n", rows[i].id, flags, previousID, rows[i].id)
+		fmt.Printf("    If its changes are already in the database, run the output of `roamer %s-stamp sql %s %s` to only record it as applied.\n", flags, previousID, rows[i].id)
+	}
+
+	for _, row := range rows {
+		if row.missing {
+			fmt.Printf("  * Migration %s is not on disk. Restore its files, or, if you know what you're doing, remove it with:\n", row.id)
+			fmt.Printf("        DELETE FROM %s WHERE id = '%s';\n", environment.GetHistoryTableName(), row.id)
+		}
+	}
+
+	for _, row := range rows {
+		if row.dirty {
+			fmt.Printf("  * Migration %s is dirty. Connect to the database and manually resolve the issue, then either delete the migration from the %s table or set its dirty flag to 0.\n", row.id, environment.GetHistoryTableName())
+		}
+	}
+
+	if len(heads) > 1 {
+		fmt.Printf("  * The revision graph has multiple heads (%s). Merge them by doing `roamer merge <description>`.\n", strings.Join(heads, ", "))
+	}
+
+	if len(skippedRows) == 0 && len(heads) < 2 && !haveMissing && !haveDirty {
+		fmt.Println("  * Check the " + environment.GetHistoryTableName() + " table and compare it to the migrations on disk.")
+	}
+
+	return false
+}
+
+// selectionFlags returns the -stream and -db flags that select the environment's stream and database, each followed by
+// a space, for use in suggested commands. It is empty if the environment uses the default ones.
+func selectionFlags(environment *roamer.Environment) string {
+	flags := ""
+	if environment.Stream() != "" {
+		flags += "-stream " + environment.Stream() + " "
+	}
+	if environment.Database() != "" {
+		flags += "-db " + environment.Database() + " "
+	}
+
+	return flags
+}
+
+// headIDs returns the IDs of the heads of the environment's revision graph, or nil if it doesn't have one.
+func headIDs(environment *roamer.Environment) []string {
+	if !environment.HasRevisionGraph() {
+		return nil
+	}
+
+	ids := []string{}
+	for _, head := range environment.Heads() {
+		ids = append(ids, head.ID)
+	}
+
+	return ids
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+// captureOutput returns what the given function prints to stdout.
+func captureOutput(t *testing.T, f func()) string {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+
+	f()
+	writer.Close()
+
+	return <-output
+}
+
+func TestGetMergedStatusRows(t *testing.T) {
+	allMigrations := []roamer.Migration{
+		{ID: "1700000001", Description: "A"},
+		{ID: "1700000003", Description: "B"},
+		{ID: "1700000004", Description: "C"},
+		{ID: "1700000005", Description: "D"},
+	}
+
+	tests := []struct {
+		name              string
+		appliedMigrations []roamer.AppliedMigration
+		expected          []mergedStatusRow
+	}{
+		{
+			"in order",
+			[]roamer.AppliedMigration{
+				{ID: "1700000001", AppliedAt: 10},
+				{ID: "1700000003", AppliedAt: 20},
+			},
+			[]mergedStatusRow{
+				{diskPosition: 1, historyPosition: 1, id: "1700000001", description: "A"},
+				{diskPosition: 2, historyPosition: 2, id: "1700000003", description: "B"},
+				{diskPosition: 3, id: "1700000004", description: "C"},
+				{diskPosition: 4, id: "1700000005", description: "D"},
+			},
+		},
+		{
+			"skipped",
+			[]roamer.AppliedMigration{
+				{ID: "1700000001", AppliedAt: 10},
+				{ID: "1700000004", AppliedAt: 20},
+			},
+			[]mergedStatusRow{
+				{diskPosition: 1, historyPosition: 1, id: "1700000001", description: "A"},
+				{diskPosition: 2, id: "1700000003", description: "B", skipped: true},
+				{diskPosition: 3, historyPosition: 2, id: "1700000004", description: "C"},
+				{diskPosition: 4, id: "1700000005", description: "D"},
+			},
+		},
+		{
+			"out of order",
+			[]roamer.AppliedMigration{
+				{ID: "1700000001", AppliedAt: 10},
+				{ID: "1700000003", AppliedAt: 30},
+				{ID: "1700000004", AppliedAt: 20},
+			},
+			[]mergedStatusRow{
+				{diskPosition: 1, historyPosition: 1, id: "1700000001", description: "A"},
+				{diskPosition: 2, historyPosition: 3, id: "1700000003", description: "B", outOfOrder: true},
+				{diskPosition: 3, historyPosition: 2, id: "1700000004", description: "C"},
+				{diskPosition: 4, id: "1700000005", description: "D"},
+			},
+		},
+		{
+			"missing and dirty",
+			[]roamer.AppliedMigration{
+				{ID: "1700000001", AppliedAt: 10},
+				{ID: "1700000002", AppliedAt: 20},
+				{ID: "1700000003", AppliedAt: 20, Dirty: true},
+			},
+			[]mergedStatusRow{
+				{diskPosition: 1, historyPosition: 1, id: "1700000001", description: "A"},
+				{historyPosition: 2, id: "1700000002", missing: true},
+				{diskPosition: 2, historyPosition: 3, id: "1700000003", description: "B", dirty: true},
+				{diskPosition: 3, id: "1700000004", description: "C"},
+				{diskPosition: 4, id: "1700000005", description: "D"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		rows := getMergedStatusRows(allMigrations, test.appliedMigrations)
+		if !reflect.DeepEqual(rows, test.expected) {
+			t.Errorf("%s: got\n%+v\nexpected\n%+v", test.name, rows, test.expected)
+		}
+	}
+}
+
+func TestGetOutOfOrderStatusEntries(t *testing.T) {
+	allMigrations := []roamer.Migration{
+		{ID: "1700000001", Description: "A"},
+		{ID: "1700000002", Description: "B"},
+		{ID: "1700000003", Description: "C"},
+	}
+	appliedMigrations := []roamer.AppliedMigration{
+		{ID: "1700000001", AppliedAt: 10},
+		{ID: "1700000003", AppliedAt: 20},
+	}
+
+	for _, allowOutOfOrder := range []bool{false, true} {
+		entries := getOutOfOrderStatusEntries(allMigrations, appliedMigrations, allowOutOfOrder)
+		if len(entries) != 3 {
+			t.Fatalf("expected 3 entries, got %d", len(entries))
+		}
+
+		skipped := entries[1]
+		if skipped.ID != "1700000002" || skipped.State != statusStatePending || skipped.AppliedAt != nil {
+			t.Errorf("unexpected entry %+v", skipped)
+		}
+
+		// when out-of-order migrations are allowed, the next upgrade just applies it, so it isn't a problem
+		if skipped.Skipped == allowOutOfOrder {
+			t.Errorf("with allowOutOfOrder %t, expected skipped to be %t", allowOutOfOrder, !allowOutOfOrder)
+		}
+	}
+}
+
+func TestPrintMergedStatusSuggestionFlags(t *testing.T) {
+	directory := t.TempDir()
+	for _, id := range []string{"1700000001", "1700000002", "1700000003"} {
+		for _, direction := range []string{"up", "down"} {
+			err := os.WriteFile(filepath.Join(directory, id+"_migration_"+direction+".sql"), []byte("-- Description: Migration\nSELECT 1;\n"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	config := roamer.DefaultConfig
+	config.Environment.MinimumVersion = ""
+	config.Streams = map[string]roamer.StreamConfig{"reports": {MigrationDirectory: "reports/"}}
+	config.Databases = map[string]roamer.DatabaseConfig{"analytics": {MigrationDirectory: "analytics/"}}
+	localConfig := roamer.LocalConfig{
+		Database:  roamer.LocalDatabaseConfig{Driver: roamer.DriverTypeMySQL},
+		Databases: map[string]roamer.LocalDatabaseConfig{"analytics": {Driver: roamer.DriverTypeMySQL}},
+	}
+
+	// 1700000002 was skipped
+	appliedMigrations := []roamer.AppliedMigration{
+		{ID: "1700000001", AppliedAt: 10},
+		{ID: "1700000003", AppliedAt: 20},
+	}
+
+	tests := []struct {
+		option   roamer.EnvironmentOption
+		expected string
+	}{
+		{nil, "`roamer sql 1700000001 1700000002`"},
+		{roamer.WithStream("reports"), "`roamer -stream reports sql 1700000001 1700000002`"},
+		{roamer.WithDatabase("analytics"), "`roamer -db analytics -stamp sql 1700000001 1700000002`"},
+	}
+
+	for _, test := range tests {
+		options := []roamer.EnvironmentOption{}
+		if test.option != nil {
+			options = append(options, test.option)
+		}
+		environment, err := roamer.NewOfflineEnvironment(config, localConfig, http.Dir(directory), options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		allMigrations, err := environment.ListAllMigrations()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		output := captureOutput(t, func() {
+			printMergedStatus(environment, allMigrations, appliedMigrations, false)
+		})
+		if !strings.Contains(output, test.expected) {
+			t.Errorf("expected the suggestions to include %s, got\n%s", test.expected, output)
+		}
+	}
+}
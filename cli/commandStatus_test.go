@@ -0,0 +1,93 @@
+//go:build !nocgo
+// +build !nocgo
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+// newStatusTestEnvironment creates an environment connected to an empty in-memory SQLite database, with a migration
+// for each of the given IDs, which follows the parent given for it, if any.
+func newStatusTestEnvironment(t *testing.T, ids []string, parents map[string]string) *roamer.Environment {
+	t.Helper()
+
+	directory := t.TempDir()
+	for _, id := range ids {
+		contents := "-- Description: Migration " + id + "\n"
+		if parents[id] != "" {
+			contents += "-- Parent: " + parents[id] + "\n"
+		}
+		contents += "SELECT 1;\n"
+
+		for _, direction := range []string{"up", "down"} {
+			err := os.WriteFile(filepath.Join(directory, id+"_migration_"+direction+".sql"), []byte(contents), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	config := roamer.DefaultConfig
+	config.Environment.MinimumVersion = ""
+	localConfig := roamer.LocalConfig{Database: roamer.LocalDatabaseConfig{Driver: roamer.DriverTypeSQLite3}}
+
+	environment, err := roamer.NewEnvironment(config, localConfig, db, http.Dir(directory))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return environment
+}
+
+func TestPrintStatusMultipleHeads(t *testing.T) {
+	environment := newStatusTestEnvironment(t, []string{"1", "2", "3"}, map[string]string{
+		"2": "1",
+		"3": "1",
+	})
+
+	for _, format := range []string{"text", "json"} {
+		var ok bool
+		var err error
+		output := captureOutput(t, func() {
+			ok, err = printStatus(context.Background(), environment, commandOptions{format: format})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok {
+			t.Errorf("%s: expected the status to fail with multiple heads, got\n%s", format, output)
+		}
+		if format == "text" && !strings.Contains(output, "multiple heads (2, 3)") {
+			t.Errorf("%s: expected the heads to be listed, got\n%s", format, output)
+		}
+	}
+
+	environment = newStatusTestEnvironment(t, []string{"1", "2", "3"}, map[string]string{
+		"2": "1",
+		"3": "2",
+	})
+	var ok bool
+	captureOutput(t, func() {
+		ok, _ = printStatus(context.Background(), environment, commandOptions{format: "text"})
+	})
+	if !ok {
+		t.Error("expected the status of a single head to be fine")
+	}
+}
@@ -1,44 +1,54 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"os"
 
 	"github.com/thatoddmailbox/roamer"
 )
 
-func commandUpgrade(environment *roamer.Environment, options commandOptions, args []string) {
-	err := requireSafe(environment)
+func commandUpgrade(ctx context.Context, environment *roamer.Environment, options commandOptions, args []string) error {
+	err := requireSafe(ctx, environment)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	allMigrations, err := environment.ListAllMigrations()
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	lastAppliedMigration, err := environment.GetLastAppliedMigration()
+	lastAppliedMigration, err := environment.GetLastAppliedMigrationContext(ctx)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	if len(allMigrations) == 0 {
 		fmt.Println("There are no migrations.")
 		fmt.Println("Get started by doing `roamer create <description>`")
-		os.Exit(1)
-		return
+		return exitError{exitCodeProblem}
 	}
 
 	latestMigration := allMigrations[len(allMigrations)-1]
 
-	if lastAppliedMigration != nil {
+	if environment.AllowsOutOfOrder() {
+		// earlier migrations might still need to be applied, so check all of them
+		appliedMigrations, err := environment.ListAppliedMigrationsContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(appliedMigrations) >= len(allMigrations) {
+			fmt.Println("The database is already up-to-date.")
+			return nil
+		}
+	} else if lastAppliedMigration != nil {
 		if latestMigration.ID == lastAppliedMigration.ID {
 			fmt.Println("The database is already up-to-date.")
-			return
+			return nil
 		}
 	}
 
 	// we rewrite this as a go command to the latest migration
-	commandGo(environment, options, []string{latestMigration.ID})
+	return commandGo(ctx, environment, options, []string{latestMigration.ID})
 }
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+type verifyProblemOutput struct {
+	Kind      string `json:"kind"`
+	ID        string `json:"id,omitempty"`
+	RelatedID string `json:"relatedId,omitempty"`
+	Message   string `json:"message"`
+}
+
+type verifyCheckOutput struct {
+	Name     string                `json:"name"`
+	Status   string                `json:"status"`
+	Reason   string                `json:"reason,omitempty"`
+	Problems []verifyProblemOutput `json:"problems"`
+}
+
+type verifyOutput struct {
+	OK      bool                `json:"ok"`
+	Offline bool                `json:"offline"`
+	Checks  []verifyCheckOutput `json:"checks"`
+}
+
+// add adds a check to the output, with the given problems.
+func (o *verifyOutput) add(name string, problems []verifyProblemOutput) {
+	status := "ok"
+	if len(problems) > 0 {
+		status = "failed"
+		o.OK = false
+	}
+
+	o.Checks = append(o.Checks, verifyCheckOutput{
+		Name:     name,
+		Status:   status,
+		Problems: problems,
+	})
+}
+
+// skip adds a check that could not run to the output, with the reason why.
+func (o *verifyOutput) skip(name string, reason string) {
+	o.Checks = append(o.Checks, verifyCheckOutput{
+		Name:     name,
+		Status:   "skipped",
+		Reason:   reason,
+		Problems: []verifyProblemOutput{},
+	})
+}
+
+// errorProblems converts errors into problems for the output, using the given kind for any that aren't a VerificationProblem.
+func errorProblems(kind string, errs []error) []verifyProblemOutput {
+	problems := []verifyProblemOutput{}
+	for _, err := range errs {
+		var verificationProblem roamer.VerificationProblem
+		if errors.As(err, &verificationProblem) {
+			problems = append(problems, verifyProblemOutput{
+				Kind:      verificationProblem.KindName(),
+				ID:        verificationProblem.ID,
+				RelatedID: verificationProblem.RelatedID,
+				Message:   describeError(verificationProblem),
+			})
+			continue
+		}
+
+		problems = append(problems, verifyProblemOutput{
+			Kind:    kind,
+			Message: describeError(err),
+		})
+	}
+
+	return problems
+}
+
+// listBaseMigrationIDs returns the IDs of the environment's migrations at the given git ref, leaving out its callbacks.
+func listBaseMigrationIDs(ctx context.Context, environment *roamer.Environment, baseRef string) ([]string, error) {
+	output, err := exec.CommandContext(ctx, "git", "-C", environment.MigrationDirectoryPath(), "ls-tree", "--name-only", baseRef, "--", ".").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, filename := range strings.Split(string(output), "\n") {
+		if !strings.HasSuffix(filename, "_up.sql") || environment.IsCallbackFilename(filename) {
+			continue
+		}
+
+		ids = append(ids, strings.Split(filename, "_")[0])
+	}
+
+	return ids, nil
+}
+
+// checkAgainstBase finds new migrations that would sort before a migration that is already on the base ref.
+// Once the base has been applied somewhere, such a migration would have to be applied out of order.
+// If the base ref can't be read, that is a problem too, so that a missing ref can't make verify pass without checking.
+func checkAgainstBase(ctx context.Context, environment *roamer.Environment, baseRef string) ([]verifyProblemOutput, string) {
+	if environment.AllowsOutOfOrder() {
+		return nil, "the environment allows out-of-order migrations"
+	}
+
+	baseIDs, err := listBaseMigrationIDs(ctx, environment, baseRef)
+	if err != nil {
+		return []verifyProblemOutput{{
+			Kind:    "baseUnavailable",
+			Message: fmt.Sprintf("could not list the migrations at %s, so new migrations could not be checked against it (%s); fetch it, or choose another ref with -base-ref", baseRef, err),
+		}}, ""
+	}
+
+	newestBaseID := ""
+	onBase := map[string]bool{}
+	for _, id := range baseIDs {
+		onBase[id] = true
+		if id > newestBaseID {
+			newestBaseID = id
+		}
+	}
+
+	migrations, err := environment.ListAllMigrations()
+	if err != nil {
+		return errorProblems("error", []error{err}), ""
+	}
+
+	problems := []verifyProblemOutput{}
+	for _, migration := range migrations {
+		if onBase[migration.ID] || migration.ID > newestBaseID {
+			continue
+		}
+
+		problems = append(problems, verifyProblemOutput{
+			Kind:      "olderThanBase",
+			ID:        migration.ID,
+			RelatedID: newestBaseID,
+			Message:   fmt.Sprintf("migration %s is not on %s, but comes before %s, which is", migration.ID, baseRef, newestBaseID),
+		})
+	}
+
+	return problems, ""
+}
+
+func commandVerify(ctx context.Context, environment *roamer.Environment, options commandOptions, args []string) error {
+	output := verifyOutput{
+		OK:      true,
+		Offline: options.offline,
+		Checks:  []verifyCheckOutput{},
+	}
+
+	checkedEnvironment, layoutProblems := roamer.CheckMigrationFiles(options.environmentPath, options.localConfig, options.environmentOptions...)
+	output.add("Migration files", errorProblems("layout", layoutProblems))
+
+	if checkedEnvironment == nil {
+		output.skip("Base branch", "the migration files have problems")
+	} else {
+		baseProblems, skipReason := checkAgainstBase(ctx, checkedEnvironment, options.baseRef)
+		if skipReason != "" {
+			output.skip("Base branch", skipReason)
+		} else {
+			output.add("Base branch", baseProblems)
+		}
+	}
+
+	verifications := []struct {
+		name   string
+		verify func(*roamer.Environment, context.Context) (roamer.VerificationReport, error)
+	}{
+		{"No dirty migrations", (*roamer.Environment).VerifyNoDirtyContext},
+		{"Applied migrations exist", (*roamer.Environment).VerifyExistContext},
+		{"Migration order", (*roamer.Environment).VerifyOrderContext},
+		{"Checksums", (*roamer.Environment).VerifyChecksumsContext},
+	}
+
+	if !options.offline {
+		skipReason := ""
+		if checkedEnvironment == nil {
+			skipReason = "the migration files have problems"
+			output.skip("Database connection", skipReason)
+		} else {
+			connectedEnvironment, err := roamer.NewEnvironmentFromDisk(options.environmentPath, options.localConfig, options.environmentOptions...)
+			if err != nil {
+				skipReason = "could not connect to the database"
+				output.add("Database connection", errorProblems("connection", []error{err}))
+			} else {
+				output.add("Database connection", []verifyProblemOutput{})
+				environment = connectedEnvironment
+			}
+		}
+
+		for _, verification := range verifications {
+			if skipReason != "" {
+				output.skip(verification.name, skipReason)
+				continue
+			}
+
+			report, err := verification.verify(environment, ctx)
+			if err != nil {
+				output.add(verification.name, errorProblems("error", []error{err}))
+				continue
+			}
+
+			problems := []error{}
+			for _, problem := range report.Problems {
+				problems = append(problems, problem)
+			}
+			output.add(verification.name, errorProblems("error", problems))
+		}
+	}
+
+	if options.format == "json" {
+		err := printJSON(output)
+		if err != nil {
+			return err
+		}
+	} else {
+		problemCount := 0
+		for _, check := range output.Checks {
+			if check.Reason != "" {
+				fmt.Printf("%-8s %s (%s)\n", check.Status, check.Name, check.Reason)
+			} else {
+				fmt.Printf("%-8s %s\n", check.Status, check.Name)
+			}
+			for _, problem := range check.Problems {
+				fmt.Printf("         - %s\n", problem.Message)
+			}
+
+			problemCount += len(check.Problems)
+		}
+
+		fmt.Println("")
+		if output.OK {
+			fmt.Println("No problems found.")
+		} else if problemCount == 1 {
+			fmt.Println("Found 1 problem.")
+		} else {
+			fmt.Printf("Found %d problems.\n", problemCount)
+		}
+	}
+
+	if !output.OK {
+		return exitError{exitCodeProblem}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// flagEnvironmentVariables lists the flags that can also be set with environment variables, and those variables.
+var flagEnvironmentVariables = []struct {
+	flag     string
+	variable string
+}{
+	{"env", "ROAMER_ENV"},
+	{"local-config", "ROAMER_LOCAL_CONFIG"},
+	{"driver", "ROAMER_DRIVER"},
+	{"dsn", "ROAMER_DSN"},
+	{"force", "ROAMER_FORCE"},
+	{"stamp", "ROAMER_STAMP"},
+}
+
+// applyEnvironmentVariables sets each flag in the given set that wasn't given on the command line from its environment
+// variable, if that is set. This gives flags precedence over environment variables, which in turn have precedence over
+// the config files.
+func applyEnvironmentVariables(flags *flag.FlagSet) error {
+	givenFlags := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		givenFlags[f.Name] = true
+	})
+
+	for _, flagEnvironmentVariable := range flagEnvironmentVariables {
+		if givenFlags[flagEnvironmentVariable.flag] {
+			continue
+		}
+
+		value, set := os.LookupEnv(flagEnvironmentVariable.variable)
+		if !set {
+			continue
+		}
+
+		// the value might be a DSN with a password in it, so it's left out of the error
+		err := flags.Set(flagEnvironmentVariable.flag, value)
+		if err != nil {
+			return fmt.Errorf("%s has an invalid value", flagEnvironmentVariable.variable)
+		}
+	}
+
+	return nil
+}
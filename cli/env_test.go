@@ -0,0 +1,77 @@
+//go:build !nocgo
+// +build !nocgo
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+func TestApplyEnvironmentVariablesPrecedence(t *testing.T) {
+	basePath := t.TempDir()
+	fileDSN := filepath.Join(basePath, "file.sqlite")
+	environmentDSN := filepath.Join(basePath, "environment.sqlite")
+	flagDSN := filepath.Join(basePath, "flag.sqlite")
+
+	err := os.WriteFile(filepath.Join(basePath, "roamer.toml"), []byte("[Environment]\nMigrationDirectory = \"migrations/\"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(basePath, "roamer.local.toml"), []byte("[Database]\nDriver = \"sqlite3\"\nDSN = \""+fileDSN+"\"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Mkdir(filepath.Join(basePath, "migrations"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		environment string
+		expected    string
+	}{
+		{"file", []string{}, "", fileDSN},
+		{"environment variable", []string{}, environmentDSN, environmentDSN},
+		{"flag", []string{"-dsn", flagDSN}, environmentDSN, flagDSN},
+	}
+
+	for _, test := range tests {
+		t.Setenv("ROAMER_DSN", test.environment)
+		if test.environment == "" {
+			os.Unsetenv("ROAMER_DSN")
+		}
+
+		flags := flag.NewFlagSet("roamer", flag.ContinueOnError)
+		dsn := flags.String("dsn", "", "")
+		err := flags.Parse(test.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = applyEnvironmentVariables(flags)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// like main, only replace the connection when there's a DSN to replace it with
+		environmentOptions := []roamer.EnvironmentOption{}
+		if *dsn != "" {
+			environmentOptions = append(environmentOptions, roamer.WithConnection("", *dsn))
+		}
+
+		environment, err := roamer.NewEnvironmentFromDisk(basePath, "local", environmentOptions...)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if environment.LocalConfig.Database.DSN != test.expected {
+			t.Errorf("%s: connected to %s, expected %s", test.name, environment.LocalConfig.Database.DSN, test.expected)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+// The exit codes that roamer uses, so that scripts can tell what kind of problem stopped it.
+const (
+	// exitCodeProblem means that roamer found a problem, such as a dirty migration in status, or hit an unexpected error.
+	exitCodeProblem = 1
+
+	// exitCodeUsage means that roamer was used incorrectly, such as with an invalid flag or a migration that doesn't exist.
+	exitCodeUsage = 2
+
+	// exitCodeConfig means that the environment could not be loaded, because of its config or its migration files.
+	exitCodeConfig = 3
+
+	// exitCodeConnection means that roamer could not connect to the database.
+	exitCodeConnection = 4
+
+	// exitCodeNotSafe means that it is not safe to apply migrations, because of the state of the database.
+	exitCodeNotSafe = 5
+
+	// exitCodeMigration means that a migration or callback failed, which might have left the database in an inconsistent state.
+	exitCodeMigration = 6
+
+	// exitCodeInterrupted means that roamer was interrupted or cancelled, or ran out of time.
+	exitCodeInterrupted = 7
+)
+
+// exitCodeDescriptions describes each exit code for the help text.
+var exitCodeDescriptions = []struct {
+	code        int
+	description string
+}{
+	{0, "Success"},
+	{exitCodeProblem, "A problem was found, such as a dirty migration, or there was an unexpected error"},
+	{exitCodeUsage, "Incorrect usage, such as an invalid flag, migration ID, or offset"},
+	{exitCodeConfig, "The environment could not be loaded, because of its config or its migration files"},
+	{exitCodeConnection, "Could not connect to the database"},
+	{exitCodeNotSafe, "It is not safe to apply migrations, because of the state of the database"},
+	{exitCodeMigration, "A migration or callback failed"},
+	{exitCodeInterrupted, "Interrupted, cancelled, or ran out of time"},
+}
+
+// An exitError makes roamer exit with the given code, after the command that returned it has already explained why.
+type exitError struct {
+	code int
+}
+
+// Error returns a string representation of the exitError.
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit code %d", e.code)
+}
+
+// An environmentError is an error from loading an environment.
+type environmentError struct {
+	inner error
+}
+
+// Error returns a string representation of the environmentError.
+func (e environmentError) Error() string {
+	return e.inner.Error()
+}
+
+// Unwrap returns the inner error of the environmentError.
+func (e environmentError) Unwrap() error {
+	return e.inner
+}
+
+// describeError returns the message of the given error, without the prefix that roamer puts on its errors.
+func describeError(err error) string {
+	return strings.TrimPrefix(err.Error(), "roamer: ")
+}
+
+// reportError explains the given error to the user, and returns the exit code that roamer should exit with.
+func reportError(err error) int {
+	var exitErr exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+
+	var connectionErr roamer.ConnectionError
+	var undecodedErr roamer.UndecodedConfigError
+	var offsetBoundErr roamer.OffsetBoundError
+	var invalidInputErr roamer.InvalidInputError
+	var multipleHeadsErr roamer.MultipleHeadsError
+	var operationErr roamer.OperationError
+	var callbackErr roamer.CallbackError
+	var environmentErr environmentError
+	var mysqlErr *mysql.MySQLError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &connectionErr):
+		// the message of err, rather than of connectionErr, has any secrets removed
+		fmt.Fprintf(os.Stderr, "%s.\n", capitalize(describeError(err)))
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1045 {
+			fmt.Fprintln(os.Stderr, "Check the user and password in your local config.")
+		} else if errors.As(err, &mysqlErr) && (mysqlErr.Number == 1044 || mysqlErr.Number == 1049) {
+			fmt.Fprintln(os.Stderr, "Check that the database in your local config exists, and that the user can access it.")
+		} else if errors.As(err, &netErr) {
+			fmt.Fprintln(os.Stderr, "Check that the database server is running, and that the address in your local config is correct.")
+		}
+		return exitCodeConnection
+
+	case errors.As(err, &undecodedErr):
+		keys := []string{}
+		for _, key := range undecodedErr.Undecoded {
+			keys = append(keys, key.String())
+		}
+
+		fmt.Fprintf(os.Stderr, "Your %s file has settings that roamer does not recognize: %s.\n", undecodedErr.Filename, strings.Join(keys, ", "))
+		fmt.Fprintln(os.Stderr, "Check them for typos, or, if they are for a newer version of roamer, upgrade roamer.")
+		return exitCodeConfig
+
+	case errors.Is(err, roamer.ErrVersionTooOld):
+		fmt.Fprintf(os.Stderr, "This environment requires a newer version of roamer than this one, which is version %s.\n", roamer.GetVersionString())
+		return exitCodeConfig
+
+	case errors.Is(err, roamer.ErrEnvironmentMissingLocalConfig):
+		fmt.Fprintln(os.Stderr, "This environment does not have a local config file yet.")
+		fmt.Fprintln(os.Stderr, "Do `roamer setup` to create one, or pass the database to connect to with -dsn.")
+		return exitCodeConfig
+
+	case errors.Is(err, roamer.ErrLintDriverUnknown):
+		fmt.Fprintln(os.Stderr, "Lint needs to know which database driver the migrations are written for, but this environment has no local config file.")
+		fmt.Fprintln(os.Stderr, "Set Driver in the Lint section of roamer.toml, or pass it with -driver.")
+		return exitCodeConfig
+
+	case errors.As(err, &offsetBoundErr):
+		fmt.Fprintf(os.Stderr, "Offset %s goes past the first or last migration.\n", offsetBoundErr.Input)
+		return exitCodeUsage
+
+	case errors.As(err, &invalidInputErr):
+		fmt.Fprintf(os.Stderr, "'%s' is not a migration ID or an offset, such as @2, @+1, or @-1.\n", invalidInputErr.Input)
+		return exitCodeUsage
+
+	case errors.As(err, &multipleHeadsErr):
+		fmt.Fprintf(os.Stderr, "The revision graph has multiple heads: %s.\n", strings.Join(multipleHeadsErr.Heads, ", "))
+		fmt.Fprintln(os.Stderr, "Merge them by doing `roamer merge <description>`.")
+		return exitCodeNotSafe
+
+	case errors.Is(err, roamer.ErrNotSafeToApply):
+		fmt.Fprintf(os.Stderr, "%s.\n", capitalize(describeError(err)))
+		return exitCodeNotSafe
+
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintln(os.Stderr, "Interrupted.")
+		return exitCodeInterrupted
+
+	case errors.Is(err, context.DeadlineExceeded):
+		fmt.Fprintf(os.Stderr, "%s.\n", capitalize(describeError(err)))
+		return exitCodeInterrupted
+
+	case errors.As(err, &operationErr), errors.As(err, &callbackErr):
+		fmt.Fprintf(os.Stderr, "%s.\n", capitalize(describeError(err)))
+		return exitCodeMigration
+
+	case errors.As(err, &environmentErr):
+		fmt.Fprintf(os.Stderr, "Could not load the environment: %s.\n", describeError(err))
+		return exitCodeConfig
+	}
+
+	fmt.Fprintf(os.Stderr, "Error: %s.\n", describeError(err))
+	return exitCodeProblem
+}
+
+// capitalize returns the given message with its first letter in upper case.
+func capitalize(message string) string {
+	if message == "" {
+		return message
+	}
+
+	return strings.ToUpper(message[:1]) + message[1:]
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// newLogger creates a logger writing to stderr in the given format, or returns nil if the level is "none".
+func newLogger(format string, level string) (*slog.Logger, error) {
+	// the format is checked even if nothing will be logged, so that a typo doesn't go unnoticed until logging is turned on
+	if format != "text" && format != "json" {
+		return nil, fmt.Errorf("unknown log format '%s'", format)
+	}
+
+	if level == "none" {
+		return nil, nil
+	}
+
+	var slogLevel slog.Level
+	err := slogLevel.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, fmt.Errorf("unknown log level '%s'", level)
+	}
+
+	handlerOptions := &slog.HandlerOptions{
+		Level: slogLevel,
+	}
+
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, handlerOptions)), nil
+	}
+
+	return slog.New(slog.NewTextHandler(os.Stderr, handlerOptions)), nil
+}
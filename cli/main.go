@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime/debug"
 
 	"github.com/thatoddmailbox/roamer"
@@ -20,6 +22,11 @@ func printHelp() {
 		fmt.Printf("  %s\n", command.Name)
 		fmt.Printf("        %s\n", command.Description)
 	}
+	fmt.Println("")
+	fmt.Println("Exit codes:")
+	for _, exitCode := range exitCodeDescriptions {
+		fmt.Printf("  %d  %s\n", exitCode.code, exitCode.description)
+	}
 
 	os.Exit(0)
 }
@@ -37,14 +44,39 @@ func findSetting(bi *debug.BuildInfo, name string) string {
 func main() {
 	flagHelp := flag.Bool("help", false, "Display usage information.")
 	flagVersion := flag.Bool("version", false, "Display the current version.")
-	flagEnvironment := flag.String("env", "./", "The directory to use as an environment.")
-	flagForce := flag.Bool("force", false, "Skip any prompts for down migrations. Useful for shell scripts that run migrations.")
-	flagLocalConfig := flag.String("local-config", "local", "The file to use as the local config.")
-	flagStamp := flag.Bool("stamp", false, "Only update the history table with the migrations, without actually running the migration scripts.")
+	flagEnvironment := flag.String("env", "", "The directory to use as an environment. By default, the current directory and then each of its parents are searched for a roamer.toml file. Can also be set with ROAMER_ENV.")
+	flagForce := flag.Bool("force", false, "Skip any prompts for down migrations. Useful for shell scripts that run migrations. Can also be set with ROAMER_FORCE.")
+	flagLocalConfig := flag.String("local-config", "local", "The file to use as the local config. Can also be set with ROAMER_LOCAL_CONFIG.")
+	flagDriver := flag.String("driver", "", "The database driver to use, either mysql or sqlite3, instead of the one in the local config. Can also be set with ROAMER_DRIVER.")
+	flagDSN := flag.String("dsn", "", "The DSN to connect to the database with, instead of the one in the local config, which is then optional. Can also be set with ROAMER_DSN.")
+	flagStream := flag.String("stream", "", "The stream of migrations to use, from the Streams section of roamer.toml. By default, the migrations in MigrationDirectory are used.")
+	flagAllStreams := flag.Bool("all-streams", false, "Run upgrade or status on every stream of migrations, one after another.")
+	flagDatabase := flag.String("db", "", "The database to use, from the Databases sections of roamer.toml and the local config. By default, the one in the Database section of the local config is used.")
+	flagAllDatabases := flag.Bool("all-databases", false, "Run upgrade or status on every database, one after another.")
+	flagStamp := flag.Bool("stamp", false, "Only update the history table with the migrations, without actually running the migration scripts. Can also be set with ROAMER_STAMP.")
+	flagTimeout := flag.Duration("timeout", 0, "The longest that go or upgrade may run for, such as 10m. Migrations that are running when the time runs out are marked as dirty.")
+	flagDryRun := flag.Bool("dry-run", false, "Print the SQL that would be executed, without changing the database.")
+	flagDryRunFormat := flag.String("dry-run-format", "text", "The format used by -dry-run, either text or sql.")
+	flagFormat := flag.String("format", "text", "The output format of status, fleet, doctor, verify, and lint, either text or json. lint can also use github, for GitHub Actions annotations.")
+	flagTargets := flag.String("targets", "", "The file listing the databases for fleet upgrade, one per line, as either a DSN or a name followed by a DSN.")
+	flagDSNTemplate := flag.String("dsn-template", "", "A DSN containing {name}, which makes each line of the -targets file a name that replaces {name}.")
+	flagConcurrency := flag.Int("concurrency", 4, "How many databases fleet upgrade may upgrade at the same time.")
+	flagFailFast := flag.Bool("fail-fast", false, "Stop fleet upgrade once a database fails, skipping the databases that haven't started.")
+	flagOffline := flag.Bool("offline", false, "Make verify only check the migration files, without connecting to the database.")
+	flagBaseRef := flag.String("base-ref", "main", "The git ref that verify compares new migrations against, such as main or origin/main. verify fails if the ref cannot be read.")
+	flagLogFormat := flag.String("log-format", "text", "The format of log messages, either text or json.")
+	flagLogLevel := flag.String("log-level", "none", "The lowest level of log messages to write to stderr, either none, debug, info, warn, or error.")
 	flag.Parse()
 
 	registerCommands()
 
+	err := applyEnvironmentVariables(flag.CommandLine)
+	if err != nil {
+		fmt.Printf("Invalid environment variable: %s.\n", err)
+		os.Exit(exitCodeUsage)
+		return
+	}
+
 	if *flagVersion {
 		vcsString := ""
 		bi, ok := debug.ReadBuildInfo()
@@ -81,50 +113,203 @@ func main() {
 		return
 	}
 
+	if *flagEnvironment == "" {
+		if args[0] == "init" || args[0] == "setup" {
+			// these set up the environment, so there's nothing to find
+			*flagEnvironment = "./"
+		} else {
+			foundEnvironment, err := roamer.FindEnvironment(".")
+			if err != nil {
+				if err == roamer.ErrEnvironmentMissingConfig {
+					fmt.Println("Could not find a roamer.toml file in the current directory or any of its parents.")
+					fmt.Println("Do `roamer init` to set up a new environment, or use -env to choose an existing one.")
+					os.Exit(exitCodeConfig)
+					return
+				}
+
+				os.Exit(reportError(err))
+				return
+			}
+
+			// this goes to stderr, so that it doesn't get mixed into output like SQL scripts and JSON
+			fmt.Fprintf(os.Stderr, "Using environment %s\n", foundEnvironment)
+
+			*flagEnvironment = foundEnvironment
+		}
+	}
+
 	envInfo, err := os.Stat(*flagEnvironment)
 	if err != nil {
 		if os.IsNotExist(err) {
 			fmt.Printf("Environment '%s' does not exist.\n", *flagEnvironment)
-			os.Exit(1)
+			os.Exit(exitCodeUsage)
 			return
 		}
 
-		panic(err)
+		os.Exit(reportError(err))
+		return
 	}
 	if !envInfo.IsDir() {
 		fmt.Printf("Environment '%s' is actually a file!\n", *flagEnvironment)
 		fmt.Println("Make sure your environment is the directory containing your roamer.toml file, not the file itself!")
-		os.Exit(1)
+		os.Exit(exitCodeUsage)
 		return
 	}
 
+	if *flagFormat == "github" && args[0] != "lint" {
+		fmt.Println("The github format can only be used with lint.")
+		os.Exit(exitCodeUsage)
+		return
+	}
+	if *flagFormat != "text" && *flagFormat != "json" && *flagFormat != "github" {
+		fmt.Printf("Unknown format '%s'. The format must be either text or json, or for lint, github.\n", *flagFormat)
+		os.Exit(exitCodeUsage)
+		return
+	}
+
+	if *flagConcurrency < 1 {
+		fmt.Println("The concurrency must be at least 1.")
+		os.Exit(exitCodeUsage)
+		return
+	}
+
+	if *flagDryRunFormat != "text" && *flagDryRunFormat != "sql" {
+		fmt.Printf("Unknown dry run format '%s'. The format must be either text or sql.\n", *flagDryRunFormat)
+		os.Exit(exitCodeUsage)
+		return
+	}
+
+	logger, err := newLogger(*flagLogFormat, *flagLogLevel)
+	if err != nil {
+		fmt.Printf("Invalid logging flags: %s.\n", err)
+		os.Exit(exitCodeUsage)
+		return
+	}
+
+	environmentOptions := []roamer.EnvironmentOption{}
+	if logger != nil {
+		environmentOptions = append(environmentOptions, roamer.WithLogger(logger))
+	}
+	if *flagDriver != "" || *flagDSN != "" {
+		environmentOptions = append(environmentOptions, roamer.WithConnection(roamer.DriverType(*flagDriver), *flagDSN))
+	}
+
 	command, commandExists := commands[args[0]]
 	if !commandExists {
 		fmt.Printf("Unknown command '%s'. Do -help to see all commands.\n", args[0])
-		os.Exit(1)
+		os.Exit(exitCodeUsage)
 		return
 	}
 
+	if (*flagStream != "" || *flagAllStreams) && (*flagDatabase != "" || *flagAllDatabases) {
+		fmt.Println("Streams and databases cannot be chosen together.")
+		os.Exit(exitCodeUsage)
+		return
+	}
+
+	if *flagAllDatabases && (*flagDriver != "" || *flagDSN != "") {
+		fmt.Println("The -all-databases flag cannot be used with -driver or -dsn.")
+		os.Exit(exitCodeUsage)
+		return
+	}
+
+	if *flagAllStreams || *flagAllDatabases {
+		if *flagStream != "" || *flagDatabase != "" {
+			fmt.Println("The -all-streams and -all-databases flags cannot be used with -stream or -db.")
+			os.Exit(exitCodeUsage)
+			return
+		}
+
+		if command.AllAction == nil {
+			fmt.Printf("The -all-streams and -all-databases flags cannot be used with '%s'.\n", command.Name)
+			os.Exit(exitCodeUsage)
+			return
+		}
+	}
+
+	selectedOptions := environmentOptions
+	if *flagStream != "" {
+		selectedOptions = append(selectedOptions, roamer.WithStream(*flagStream))
+	}
+	if *flagDatabase != "" {
+		selectedOptions = append(selectedOptions, roamer.WithDatabase(*flagDatabase))
+	}
+
 	// verify argument count
 	if len(args)-1 != len(command.Arguments) {
 		fmt.Printf("Incorrect usage of '%s'. Do -help to see usage information.\n", args[0])
-		os.Exit(1)
+		os.Exit(exitCodeUsage)
 		return
 	}
 
 	var environment *roamer.Environment
 
 	// init and setup are special cases, don't load the environment for it
-	if command.Name != "init" && command.Name != "setup" {
-		environment, err = roamer.NewEnvironmentFromDisk(*flagEnvironment, *flagLocalConfig)
+	// sql, fleet, and lint are also special, since they don't connect to the environment's own database
+	// doctor and verify load the environment themselves, so that they can report problems with it
+	if command.Name == "sql" || command.Name == "fleet" || command.Name == "lint" {
+		environment, err = roamer.NewOfflineEnvironmentFromDisk(*flagEnvironment, *flagLocalConfig, selectedOptions...)
 		if err != nil {
-			panic(err)
+			os.Exit(reportError(environmentError{err}))
+			return
 		}
-	} else {
+	} else if command.Name != "init" && command.Name != "setup" && command.Name != "doctor" && command.Name != "verify" {
+		environment, err = roamer.NewEnvironmentFromDisk(*flagEnvironment, *flagLocalConfig, selectedOptions...)
+		if err != nil {
+			os.Exit(reportError(environmentError{err}))
+			return
+		}
+	} else if command.Name != "doctor" && command.Name != "verify" {
 		// sneak in the environment path and local config names as arguments
 		// a bit of a hack but it works
 		args = []string{command.Name, *flagEnvironment, *flagLocalConfig}
 	}
 
-	command.Action(environment, commandOptions{*flagForce, *flagStamp}, args[1:])
+	// cancel whatever is running on the first interrupt, and let a second interrupt stop roamer immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	options := commandOptions{
+		force:        *flagForce,
+		stamp:        *flagStamp,
+		timeout:      *flagTimeout,
+		dryRun:       *flagDryRun,
+		dryRunFormat: *flagDryRunFormat,
+		format:       *flagFormat,
+		targets:      *flagTargets,
+		dsnTemplate:  *flagDSNTemplate,
+		concurrency:  *flagConcurrency,
+		failFast:     *flagFailFast,
+		offline:      *flagOffline,
+		baseRef:      *flagBaseRef,
+
+		environmentPath:    *flagEnvironment,
+		localConfig:        *flagLocalConfig,
+		environmentOptions: selectedOptions,
+	}
+
+	if *flagAllStreams || *flagAllDatabases {
+		var environments []namedEnvironment
+		if *flagAllStreams {
+			environments, err = streamEnvironments(environment, *flagEnvironment, *flagLocalConfig, environmentOptions)
+		} else {
+			environments, err = databaseEnvironments(environment, *flagEnvironment, *flagLocalConfig, environmentOptions)
+		}
+		if err != nil {
+			os.Exit(reportError(environmentError{err}))
+			return
+		}
+
+		err = command.AllAction(ctx, environments, options)
+	} else {
+		err = command.Action(ctx, environment, options, args[1:])
+	}
+	if err != nil {
+		stop()
+		os.Exit(reportError(err))
+	}
 }
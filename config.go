@@ -1,5 +1,7 @@
 package roamer
 
+import "sort"
+
 // An EnvironmentConfig struct defines configuration parameters related to the environment's setup.
 type EnvironmentConfig struct {
 	// MigrationDirectory defines where the migrations directory is, relative to the location of the config file.
@@ -7,23 +9,177 @@ type EnvironmentConfig struct {
 
 	// MinimumVersion defines the minimum version of roamer required for this environment.
 	MinimumVersion string
+
+	// MigrationTimeout defines how long each migration may run for by default, such as "30s" or "5m".
+	// A migration can override this with a "-- Timeout:" line in the comments at the top of its file. If empty,
+	// migrations can run for as long as they need.
+	MigrationTimeout string `toml:",omitempty"`
+
+	// AllowOutOfOrder allows migrations to be applied in a different order than they appear on disk.
+	// This is useful when migrations are created on separate branches and merged later. When enabled, operations going up
+	// apply every migration up to their target that has not been applied, even ones before the last applied migration.
+	// If the migrations form a revision graph, this lets branches be applied in any order, as long as each migration's
+	// parents are applied before it.
+	AllowOutOfOrder bool `toml:",omitempty"`
+}
+
+// A CallbacksConfig struct defines the names of SQL files that are run around the migrations applied by an Operation.
+// Each name has "_up.sql" or "_down.sql" added to it, depending on the direction of the Operation, and the resulting file is
+// looked up in the migrations directory. The files are optional, and are not recorded in the history table.
+// Setting a name to an empty string disables that callback.
+type CallbacksConfig struct {
+	// BeforeAll is run before the first migration.
+	BeforeAll string
+
+	// BeforeEach is run before each migration.
+	BeforeEach string
+
+	// AfterEach is run after each migration.
+	AfterEach string
+
+	// AfterAll is run after the last migration.
+	AfterAll string
+}
+
+// A StreamConfig struct defines an additional stream of migrations, which is ordered and tracked separately from the others.
+type StreamConfig struct {
+	// MigrationDirectory defines where the stream's migrations directory is, relative to the location of the config file.
+	MigrationDirectory string
+
+	// HistoryTable defines the name of the table used to track the stream's history.
+	// If empty, it is "roamer_history_" followed by the name of the stream.
+	HistoryTable string
 }
 
+// A DatabaseConfig struct defines the migrations of an additional database.
+// The database is connected to using the entry with the same name in the Databases section of the local config.
+type DatabaseConfig struct {
+	// MigrationDirectory defines where the database's migrations directory is, relative to the location of the config file.
+	MigrationDirectory string
+}
+
+// A LintConfig struct defines how Lint checks the migration files.
+type LintConfig struct {
+	// Driver is the database driver that the migrations are written for, either mysql or sqlite3, which some rules depend on.
+	// It lets the migrations be checked without a local config file, such as in CI.
+	Driver DriverType `toml:",omitempty"`
+
+	// Rules sets the severity of lint rules by name, to "error", "warning", or "off".
+	// Rules that are not listed keep their default severity.
+	Rules map[string]LintSeverity
+}
+
+// DefaultName is the name used for the migrations in Environment.MigrationDirectory and the database in the Database
+// section of the local config, such as when listing every stream or database, so it can't be used for either.
+const DefaultName = "default"
+
 // A Config struct defines some configuration parameters for roamer.
 type Config struct {
 	Environment EnvironmentConfig
+	Callbacks   CallbacksConfig
+
+	// Streams defines additional streams of migrations, by name, alongside the ones in Environment.MigrationDirectory.
+	// None of them can be named DefaultName.
+	Streams map[string]StreamConfig
+
+	// Databases defines additional databases, by name, alongside the one in the Database section of the local config.
+	// None of them can be named DefaultName.
+	Databases map[string]DatabaseConfig
+
+	// Lint defines how Lint checks the migration files. If it is nil, every rule has its default severity.
+	// It's a pointer so that configs without it are written without an empty section.
+	Lint *LintConfig
+}
+
+// StreamNames returns the names of the additional streams of migrations, in alphabetical order.
+func (c Config) StreamNames() []string {
+	names := []string{}
+	for name := range c.Streams {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// lintConfig returns the Lint section of the config, or an empty one if it doesn't have one.
+func (c Config) lintConfig() LintConfig {
+	if c.Lint == nil {
+		return LintConfig{}
+	}
+
+	return *c.Lint
+}
+
+// DatabaseNames returns the names of the additional databases, in alphabetical order.
+func (c Config) DatabaseNames() []string {
+	names := []string{}
+	for name := range c.Databases {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
 }
 
 // A LocalDatabaseConfig struct defines configuration parameters for the database connection.
 type LocalDatabaseConfig struct {
 	Driver DriverType
-	DSN    string
+
+	// DSN describes how to connect to the database. Any ${VAR} in it is replaced with the value of that environment variable.
+	DSN string
+
+	// PasswordFile is the path to a file containing the database password, relative to the location of the config file.
+	// A trailing newline is ignored. The password replaces any password in the DSN. It can only be used with mysql.
+	PasswordFile string `toml:",omitempty"`
+
+	// PasswordCommand is a command that prints the database password, run in the directory of the config file.
+	// A trailing newline is ignored. The password replaces any password in the DSN. It can only be used with mysql.
+	PasswordCommand string `toml:",omitempty"`
+
+	// The remaining options can only be used with mysql. Each one that is set replaces the matching part of the DSN,
+	// which can then be left empty.
+
+	// Host is the host name or IP address of the server.
+	Host string `toml:",omitempty"`
+
+	// Port is the TCP port of the server. If Host is set and Port is not, it is 3306.
+	Port int `toml:",omitzero"`
+
+	// User is the user to connect as.
+	User string `toml:",omitempty"`
+
+	// DatabaseName is the name of the database to use on the server.
+	DatabaseName string `toml:",omitempty"`
+
+	// TLS is the TLS mode, either true, false, skip-verify, or preferred.
+	// If any certificate files are set, it defaults to true, and cannot be false or preferred, since preferred falls back
+	// to an unverified or unencrypted connection, which the certificates would give a false sense of security about.
+	TLS string `toml:",omitempty"`
+
+	// CACert is the path to a PEM file with the certificate authorities used to verify the server, relative to the config file.
+	CACert string `toml:",omitempty"`
+
+	// ClientCert and ClientKey are the paths to the PEM files of a client certificate and its key, relative to the config file.
+	ClientCert string `toml:",omitempty"`
+	ClientKey  string `toml:",omitempty"`
+
+	// ConnectTimeout is how long to wait for a connection to be made, such as "5s".
+	ConnectTimeout string `toml:",omitempty"`
+
+	// Charset is the character set used for the connection, such as "utf8mb4".
+	Charset string `toml:",omitempty"`
 }
 
 // A LocalConfig struct defines several local configuration parameters for roamer.
 // This is mainly used for describing a database connection
 type LocalConfig struct {
 	Database LocalDatabaseConfig
+
+	// Databases describes the connections to the additional databases defined in the Databases section of the config, by name.
+	Databases map[string]LocalDatabaseConfig
 }
 
 // DefaultConfig contains the default configuration options, used when creating a new environment.
@@ -32,6 +188,12 @@ var DefaultConfig = Config{
 		MigrationDirectory: "migrations/",
 		MinimumVersion:     GetVersionString(),
 	},
+	Callbacks: CallbacksConfig{
+		BeforeAll:  string(CallbackBeforeAll),
+		BeforeEach: string(CallbackBeforeEach),
+		AfterEach:  string(CallbackAfterEach),
+		AfterAll:   string(CallbackAfterAll),
+	},
 }
 
 // DefaultLocalConfig contains the default configuration options, used when creating a new environment.
@@ -0,0 +1,83 @@
+package roamer
+
+import (
+	"errors"
+	"fmt"
+)
+
+// WithDatabase is an EnvironmentOption that makes the Environment use the database with the given name, as defined in the
+// Databases sections of the config and the local config, instead of the one in the Database section of the local config.
+// The database has its own migrations directory.
+//
+// When using NewEnvironment or NewOfflineEnvironment, the *sql.DB and http.FileSystem given should be for that database.
+func WithDatabase(name string) EnvironmentOption {
+	return func(e *Environment) {
+		e.database = name
+	}
+}
+
+// WithConnection is an EnvironmentOption that replaces the driver and DSN from the local config with the given ones.
+// Either can be left empty to keep the one from the local config. When a DSN is given, it replaces the whole connection,
+// so the structured options and password from the local config are not used. NewEnvironmentFromDisk then does not need
+// a local config file, and if there isn't one, the driver defaults to mysql.
+func WithConnection(driver DriverType, dsn string) EnvironmentOption {
+	return func(e *Environment) {
+		e.connectionDriver = driver
+		e.connectionDSN = dsn
+	}
+}
+
+// Database returns the name of the database used by the environment, or an empty string if it uses the one in the
+// Database section of the local config.
+func (e *Environment) Database() string {
+	return e.database
+}
+
+// setUpDatabase replaces the Database section of the environment's local config with the one for its database, if it has one,
+// and then applies the driver and DSN from WithConnection.
+func (e *Environment) setUpDatabase() error {
+	if e.database != "" {
+		err := e.selectDatabase()
+		if err != nil {
+			return err
+		}
+	}
+
+	if e.connectionDriver != "" {
+		e.LocalConfig.Database.Driver = e.connectionDriver
+	}
+	if e.connectionDSN != "" {
+		// the structured options and password in the local config describe the connection in its DSN, so they're left
+		// behind with it, rather than pointing the given DSN somewhere else
+		e.LocalConfig.Database = LocalDatabaseConfig{
+			Driver: e.LocalConfig.Database.Driver,
+			DSN:    e.connectionDSN,
+		}
+	}
+
+	return nil
+}
+
+// selectDatabase replaces the Database section of the environment's local config with the one for its database.
+func (e *Environment) selectDatabase() error {
+	if e.stream != "" {
+		return errors.New("roamer: cannot use a stream and a database together")
+	}
+
+	databaseConfig, exists := e.Config.Databases[e.database]
+	if !exists {
+		return fmt.Errorf("roamer: there is no database named '%s' in roamer.toml", e.database)
+	}
+	if databaseConfig.MigrationDirectory == "" {
+		return fmt.Errorf("roamer: database '%s' is missing a MigrationDirectory", e.database)
+	}
+
+	localDatabaseConfig, exists := e.LocalConfig.Databases[e.database]
+	if !exists {
+		return fmt.Errorf("roamer: there is no database named '%s' in the local config", e.database)
+	}
+
+	e.LocalConfig.Database = localDatabaseConfig
+
+	return nil
+}
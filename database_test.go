@@ -0,0 +1,98 @@
+package roamer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testDatabasesConfig = `
+[Environment]
+MigrationDirectory = "migrations/"
+
+[Databases.analytics]
+MigrationDirectory = "analytics/"
+
+[Streams.reports]
+MigrationDirectory = "reports/"
+`
+
+const testDatabasesLocalConfig = `
+[Database]
+Driver = "mysql"
+DSN = "app@tcp(main:3306)/app"
+
+[Databases.analytics]
+Driver = "mysql"
+DSN = "app@tcp(analytics:3306)/analytics"
+`
+
+func TestWithDatabase(t *testing.T) {
+	basePath := writeTestEnvironment(t, testDatabasesConfig, testDatabasesLocalConfig, map[string]string{
+		"migrations": "1",
+		"analytics":  "2",
+		"reports":    "3",
+	})
+
+	tests := []struct {
+		database    string
+		dsn         string
+		directory   string
+		migrationID string
+	}{
+		{"", "app@tcp(main:3306)/app", "migrations", "1"},
+		{"analytics", "app@tcp(analytics:3306)/analytics", "analytics", "2"},
+	}
+
+	for _, test := range tests {
+		options := []EnvironmentOption{}
+		if test.database != "" {
+			options = append(options, WithDatabase(test.database))
+		}
+
+		env, err := NewOfflineEnvironmentFromDisk(basePath, "local", options...)
+		if err != nil {
+			t.Fatalf("database '%s': %s", test.database, err)
+		}
+
+		// each database has its own history table, so it keeps the usual name
+		if env.Database() != test.database || env.LocalConfig.Database.DSN != test.dsn || env.historyTable != "roamer_history" {
+			t.Errorf("database '%s': got database '%s' at %s with history table %s", test.database, env.Database(), env.LocalConfig.Database.DSN, env.historyTable)
+		}
+		if env.MigrationDirectoryPath() != filepath.Join(basePath, test.directory) {
+			t.Errorf("database '%s': got migrations directory %s", test.database, env.MigrationDirectoryPath())
+		}
+
+		migrations, err := env.ListAllMigrations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if migrationIDs(migrations) != test.migrationID {
+			t.Errorf("database '%s': got migrations %s, expected %s", test.database, migrationIDs(migrations), test.migrationID)
+		}
+	}
+}
+
+func TestWithDatabaseErrors(t *testing.T) {
+	basePath := writeTestEnvironment(t, testDatabasesConfig, "[Database]\nDriver = \"mysql\"\n", map[string]string{
+		"migrations": "1",
+		"analytics":  "2",
+		"reports":    "3",
+	})
+
+	tests := []struct {
+		options  []EnvironmentOption
+		expected string
+	}{
+		{[]EnvironmentOption{WithDatabase("missing")}, "there is no database named 'missing' in roamer.toml"},
+		{[]EnvironmentOption{WithDatabase("analytics")}, "there is no database named 'analytics' in the local config"},
+		{[]EnvironmentOption{WithDatabase("analytics"), WithStream("reports")}, "cannot use a stream and a database together"},
+	}
+
+	for _, test := range tests {
+		_, err := NewOfflineEnvironmentFromDisk(basePath, "local", test.options...)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected an error containing '%s', got %v", test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package roamer
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// FindEnvironment looks for the environment that contains the given path, checking it and then each of its parent
+// directories for a roamer.toml file, the way git looks for a .git directory. It returns the absolute path of the
+// first directory that has one, or ErrEnvironmentMissingConfig if none of them do.
+func FindEnvironment(startPath string) (string, error) {
+	current, err := filepath.Abs(startPath)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		info, err := os.Stat(filepath.Join(current, "roamer.toml"))
+		if err == nil && !info.IsDir() {
+			return current, nil
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", ErrEnvironmentMissingConfig
+		}
+
+		current = parent
+	}
+}
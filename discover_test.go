@@ -0,0 +1,60 @@
+package roamer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindEnvironment(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	environmentPath := filepath.Join(root, "project")
+	nestedPath := filepath.Join(environmentPath, "migrations", "nested")
+	outsidePath := filepath.Join(root, "outside")
+
+	for _, directory := range []string{nestedPath, outsidePath} {
+		err := os.MkdirAll(directory, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = os.WriteFile(filepath.Join(environmentPath, "roamer.toml"), []byte(""), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a directory named roamer.toml isn't a config file
+	err = os.Mkdir(filepath.Join(nestedPath, "roamer.toml"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		startPath string
+		expected  string
+	}{
+		{"environment", environmentPath, environmentPath},
+		{"nested directory", nestedPath, environmentPath},
+		{"not found", outsidePath, ""},
+		// the search stops at the root, rather than going around in circles
+		{"filesystem root", string(filepath.Separator), ""},
+	}
+
+	for _, test := range tests {
+		result, err := FindEnvironment(test.startPath)
+		if test.expected == "" {
+			if err != ErrEnvironmentMissingConfig {
+				t.Errorf("%s: expected ErrEnvironmentMissingConfig, got %s, %v", test.name, result, err)
+			}
+			continue
+		}
+
+		if err != nil || result != test.expected {
+			t.Errorf("%s: got %s, %v, expected %s", test.name, result, err, test.expected)
+		}
+	}
+}
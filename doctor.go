@@ -0,0 +1,196 @@
+package roamer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// A DoctorCheck is the result of one of the checks that Doctor runs on an environment.
+type DoctorCheck struct {
+	// Name describes what was checked.
+	Name string
+
+	// Problems are everything that the check found wrong, which is empty if the check passed.
+	Problems []error
+
+	// Skipped is true if the check could not run, because a check that it depends on found problems.
+	Skipped bool
+}
+
+// OK returns true if the check ran and found no problems.
+func (c DoctorCheck) OK() bool {
+	return !c.Skipped && len(c.Problems) == 0
+}
+
+// doctorCheckNames are the names of the checks that Doctor runs, in the order that it runs them.
+var doctorCheckNames = []string{
+	"Config",
+	"Migration files",
+	"Database connection",
+	"History table",
+	"No dirty migrations",
+	"Applied migrations exist",
+	"Migration order",
+}
+
+// historyTableColumnNames are the columns that the history table needs to have.
+var historyTableColumnNames = []string{"id", "appliedAt", "dirty"}
+
+// Doctor checks the environment at the given path for problems, reporting all of them instead of stopping at the first
+// one like NewEnvironmentFromDisk does. It checks the config files, the migration files, the connection to the database,
+// the shape of the history table, and finally the same things that VerifySafeToApply does.
+//
+// A check is skipped if it depends on one that found problems, so the result always has the same checks, in the same order.
+func Doctor(ctx context.Context, basePath string, localConfigName string, options ...EnvironmentOption) []DoctorCheck {
+	checks := []DoctorCheck{}
+
+	env, configProblems, canReadMigrations := diagnoseConfig(basePath, localConfigName, options)
+	checks = append(checks, DoctorCheck{Name: "Config", Problems: configProblems})
+	if !canReadMigrations {
+		return skipRemainingChecks(checks)
+	}
+
+	migrationProblems := env.readMigrations()
+	checks = append(checks, DoctorCheck{Name: "Migration files", Problems: migrationProblems})
+	if len(configProblems) > 0 {
+		return skipRemainingChecks(checks)
+	}
+
+	db, secrets, err := openDatabase(env.LocalConfig.Database, basePath)
+	if err == nil {
+		defer db.Close()
+		err = redactSecrets(env.connect(ctx, db), secrets)
+	}
+	if err != nil {
+		checks = append(checks, DoctorCheck{Name: "Database connection", Problems: []error{err}})
+		return skipRemainingChecks(checks)
+	}
+	checks = append(checks, DoctorCheck{Name: "Database connection"})
+
+	historyProblems := env.checkHistoryTable(ctx)
+	for i, problem := range historyProblems {
+		historyProblems[i] = redactSecrets(problem, secrets)
+	}
+	checks = append(checks, DoctorCheck{Name: "History table", Problems: historyProblems})
+	if len(historyProblems) > 0 || len(migrationProblems) > 0 {
+		// the verifications would compare the history against a partial list of migrations
+		return skipRemainingChecks(checks)
+	}
+
+	verifications := []struct {
+		name   string
+		verify func(context.Context) (VerificationReport, error)
+	}{
+		{"No dirty migrations", env.VerifyNoDirtyContext},
+		{"Applied migrations exist", env.VerifyExistContext},
+		{"Migration order", env.VerifyOrderContext},
+	}
+	for _, verification := range verifications {
+		check := DoctorCheck{Name: verification.name}
+
+		report, err := verification.verify(ctx)
+		if err != nil {
+			check.Problems = append(check.Problems, redactSecrets(err, secrets))
+		}
+		for _, problem := range report.Problems {
+			check.Problems = append(check.Problems, problem)
+		}
+
+		checks = append(checks, check)
+	}
+
+	return checks
+}
+
+// diagnoseConfig reads the configs of the environment at the given path, returning every problem it finds with them.
+// It also returns whether the environment got far enough to find its migrations directory.
+func diagnoseConfig(basePath string, localConfigName string, options []EnvironmentOption) (*Environment, []error, bool) {
+	err := checkEnvironmentPath(basePath)
+	if err != nil {
+		return nil, []error{err}, false
+	}
+
+	problems := []error{}
+	config, configErr := readConfigFromDisk(basePath)
+	if configErr != nil {
+		problems = append(problems, configErr)
+	}
+
+	localConfig, hasLocalConfig, err := readLocalConfigFromDisk(basePath, localConfigName)
+	if err != nil {
+		problems = append(problems, err)
+		localConfig = DefaultLocalConfig
+	}
+
+	if configErr != nil {
+		// without roamer.toml, there's no way to know where the migrations are
+		return nil, problems, false
+	}
+
+	env := prepareEnvironment(config, localConfig, nil, withOnDisk(options, basePath))
+	if err == nil && !hasLocalConfig && env.connectionDSN == "" {
+		problems = append(problems, ErrEnvironmentMissingLocalConfig)
+	}
+
+	err = env.setUp()
+	if err != nil {
+		return nil, append(problems, err), false
+	}
+
+	problems = append(problems, env.checkSettings()...)
+
+	return &env, problems, true
+}
+
+// checkHistoryTable checks that the history table, if it exists, has the columns that roamer needs and can be read.
+func (e *Environment) checkHistoryTable(ctx context.Context) []error {
+	tableExists, err := e.driver.TableExists(ctx, e.db, e.historyTable)
+	if err != nil {
+		return []error{err}
+	}
+	if !tableExists {
+		// it will be created when the first migration is applied
+		return nil
+	}
+
+	columns, err := e.driver.TableColumns(ctx, e.db, e.historyTable)
+	if err != nil {
+		return []error{err}
+	}
+
+	problems := []error{}
+	for _, expectedColumn := range historyTableColumnNames {
+		found := false
+		for _, column := range columns {
+			if strings.EqualFold(column, expectedColumn) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			problems = append(problems, fmt.Errorf("roamer: history table %s is missing the %s column", e.historyTable, expectedColumn))
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	// the columns are there, but they might not have types that roamer can read
+	_, err = e.ListAppliedMigrationsContext(ctx)
+	if err != nil {
+		return []error{fmt.Errorf("roamer: could not read history table %s: %w", e.historyTable, err)}
+	}
+
+	return nil
+}
+
+// skipRemainingChecks adds the checks that Doctor has not run yet to the given checks, marked as skipped.
+func skipRemainingChecks(checks []DoctorCheck) []DoctorCheck {
+	for _, name := range doctorCheckNames[len(checks):] {
+		checks = append(checks, DoctorCheck{Name: name, Skipped: true})
+	}
+
+	return checks
+}
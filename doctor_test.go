@@ -0,0 +1,155 @@
+//go:build !nocgo
+// +build !nocgo
+
+package roamer
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newDoctorTestEnvironment writes an environment with two migrations and a SQLite database, running the given SQL on
+// the database first, and returns its path.
+func newDoctorTestEnvironment(t *testing.T, setupSQL string) string {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "db.sqlite")
+	basePath := writeTestEnvironment(t, "[Environment]\nMigrationDirectory = \"migrations/\"\n", "[Database]\nDriver = \"sqlite3\"\nDSN = \""+dsn+"\"\n", nil)
+
+	err := os.Mkdir(filepath.Join(basePath, "migrations"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for filename, contents := range testMigrationFiles {
+		err := os.WriteFile(filepath.Join(basePath, "migrations", filename), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if setupSQL != "" {
+		db, err := sql.Open("sqlite3", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.Exec(setupSQL)
+		db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return basePath
+}
+
+// describeDoctorChecks returns the outcome of each check, such as "Config ok" or "Migration order skipped", with the
+// problems of any that failed.
+func describeDoctorChecks(checks []DoctorCheck) []string {
+	result := []string{}
+	for _, check := range checks {
+		outcome := "ok"
+		if check.Skipped {
+			outcome = "skipped"
+		} else if len(check.Problems) > 0 {
+			messages := []string{}
+			for _, problem := range check.Problems {
+				messages = append(messages, problem.Error())
+			}
+			outcome = "failed: " + strings.Join(messages, "; ")
+		}
+
+		result = append(result, check.Name+" "+outcome)
+	}
+
+	return result
+}
+
+func TestDoctor(t *testing.T) {
+	tests := []struct {
+		name     string
+		setupSQL string
+		setup    func(basePath string) error
+		expected []string
+	}{
+		{
+			// a database that hasn't had anything applied yet is fine
+			"missing history table",
+			"",
+			nil,
+			[]string{
+				"Config ok",
+				"Migration files ok",
+				"Database connection ok",
+				"History table ok",
+				"No dirty migrations ok",
+				"Applied migrations exist ok",
+				"Migration order ok",
+			},
+		},
+		{
+			"dirty migration",
+			"CREATE TABLE roamer_history (id VARCHAR(20) PRIMARY KEY, appliedAt INT(11), dirty TINYINT(1)); INSERT INTO roamer_history VALUES ('1700000001', 1, 1);",
+			nil,
+			[]string{
+				"Config ok",
+				"Migration files ok",
+				"Database connection ok",
+				"History table ok",
+				"No dirty migrations failed: roamer: migration 1700000001 is marked as dirty",
+				"Applied migrations exist ok",
+				"Migration order ok",
+			},
+		},
+		{
+			"history table without columns",
+			"CREATE TABLE roamer_history (id VARCHAR(20) PRIMARY KEY);",
+			nil,
+			[]string{
+				"Config ok",
+				"Migration files ok",
+				"Database connection ok",
+				"History table failed: roamer: history table roamer_history is missing the appliedAt column; roamer: history table roamer_history is missing the dirty column",
+				"No dirty migrations skipped",
+				"Applied migrations exist skipped",
+				"Migration order skipped",
+			},
+		},
+		{
+			// the connection is still checked, but the history can't be compared against a partial list of migrations
+			"orphaned down file",
+			"",
+			func(basePath string) error {
+				return os.WriteFile(filepath.Join(basePath, "migrations", "1700000003_orphan_down.sql"), []byte("-- Description: Orphan\n"), 0644)
+			},
+			[]string{
+				"Config ok",
+				"Migration files failed: roamer: migration file '1700000003_orphan_down.sql' did not have matching up migration",
+				"Database connection ok",
+				"History table ok",
+				"No dirty migrations skipped",
+				"Applied migrations exist skipped",
+				"Migration order skipped",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		basePath := newDoctorTestEnvironment(t, test.setupSQL)
+		if test.setup != nil {
+			err := test.setup(basePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		checks := Doctor(context.Background(), basePath, "local")
+		result := describeDoctorChecks(checks)
+		if strings.Join(result, "\n") != strings.Join(test.expected, "\n") {
+			t.Errorf("%s: got\n%s\nexpected\n%s", test.name, strings.Join(result, "\n"), strings.Join(test.expected, "\n"))
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package roamer
 
+import (
+	"context"
+	"database/sql"
+)
+
 // A DriverType describes the type of database being used with roamer.
 type DriverType string
 
@@ -9,6 +14,38 @@ const (
 	DriverTypeSQLite3 DriverType = "sqlite3"
 )
 
+// A driver looks up things that differ between databases, using whichever connection it is given, so that an
+// Operation can keep everything on the one connection that it holds.
 type driver interface {
-	TableExists(name string) (bool, error)
+	TableExists(ctx context.Context, conn execer, name string) (bool, error)
+	TableColumns(ctx context.Context, conn execer, name string) ([]string, error)
+}
+
+// An execer is something that SQL can be run on, such as a *sql.DB or a *sql.Conn.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// scanColumnNames reads the column names returned by a driver's TableColumns query, closing the rows.
+func scanColumnNames(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	columns := []string{}
+	for rows.Next() {
+		column := ""
+		err := rows.Scan(&column)
+		if err != nil {
+			return nil, err
+		}
+
+		columns = append(columns, column)
+	}
+
+	err := rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return columns, nil
 }
@@ -1,19 +1,18 @@
 package roamer
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 
 	// database driver
 	_ "github.com/go-sql-driver/mysql"
 )
 
-type driverMySQL struct {
-	db *sql.DB
-}
+type driverMySQL struct{}
 
-func (d *driverMySQL) TableExists(name string) (bool, error) {
-	rows, err := d.db.Query(
+func (d *driverMySQL) TableExists(ctx context.Context, conn execer, name string) (bool, error) {
+	rows, err := conn.QueryContext(
+		ctx,
 		"SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?",
 		name,
 	)
@@ -38,3 +37,16 @@ func (d *driverMySQL) TableExists(name string) (bool, error) {
 
 	return false, nil
 }
+
+func (d *driverMySQL) TableColumns(ctx context.Context, conn execer, name string) ([]string, error) {
+	rows, err := conn.QueryContext(
+		ctx,
+		"SELECT column_name FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position",
+		name,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanColumnNames(rows)
+}
@@ -3,7 +3,7 @@
 package roamer
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 
 	// database driver
@@ -12,12 +12,11 @@ import (
 
 const sqliteAvailable = true
 
-type driverSQLite struct {
-	db *sql.DB
-}
+type driverSQLite struct{}
 
-func (d *driverSQLite) TableExists(name string) (bool, error) {
-	rows, err := d.db.Query(
+func (d *driverSQLite) TableExists(ctx context.Context, conn execer, name string) (bool, error) {
+	rows, err := conn.QueryContext(
+		ctx,
 		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?",
 		name,
 	)
@@ -42,3 +41,12 @@ func (d *driverSQLite) TableExists(name string) (bool, error) {
 
 	return false, nil
 }
+
+func (d *driverSQLite) TableColumns(ctx context.Context, conn execer, name string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT name FROM pragma_table_info(?) ORDER BY cid", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanColumnNames(rows)
+}
@@ -3,16 +3,18 @@
 package roamer
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 )
 
 const sqliteAvailable = false
 
-type driverSQLite struct {
-	db *sql.DB
-}
+type driverSQLite struct{}
 
-func (d *driverSQLite) TableExists(name string) (bool, error) {
+func (d *driverSQLite) TableExists(ctx context.Context, conn execer, name string) (bool, error) {
 	return false, errors.New("roamer: sqlite support not available")
 }
+
+func (d *driverSQLite) TableColumns(ctx context.Context, conn execer, name string) ([]string, error) {
+	return nil, errors.New("roamer: sqlite support not available")
+}
@@ -0,0 +1,152 @@
+package roamer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var reDSNVariable = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// interpolateDSN replaces each ${VAR} in the DSN with the value of that environment variable.
+func interpolateDSN(dsn string) (string, error) {
+	var err error
+	result := reDSNVariable.ReplaceAllStringFunc(dsn, func(match string) string {
+		name := reDSNVariable.FindStringSubmatch(match)[1]
+		value, set := os.LookupEnv(name)
+		if !set {
+			if err == nil {
+				err = fmt.Errorf("roamer: the DSN uses the environment variable %s, which is not set", name)
+			}
+			return ""
+		}
+
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
+
+// mysqlDSNPassword returns the password in the given MySQL DSN, or an empty string if it doesn't have one. It finds
+// the password the same way the driver does, so that it can be found even in a DSN the driver can't otherwise parse.
+func mysqlDSNPassword(dsn string) string {
+	// the user info ends at the last @ before the database name, and the password starts after its first colon
+	slash := strings.LastIndex(dsn, "/")
+	if slash < 0 {
+		return ""
+	}
+	at := strings.LastIndex(dsn[:slash], "@")
+	if at < 0 {
+		return ""
+	}
+	colon := strings.Index(dsn[:at], ":")
+	if colon < 0 {
+		return ""
+	}
+
+	return dsn[colon+1 : at]
+}
+
+// readPassword returns the password from the PasswordFile or PasswordCommand of the given config, and whether it has one.
+// The PasswordFile path and the PasswordCommand working directory are relative to basePath.
+func readPassword(databaseConfig LocalDatabaseConfig, basePath string) (string, bool, error) {
+	if databaseConfig.PasswordFile != "" && databaseConfig.PasswordCommand != "" {
+		return "", false, errors.New("roamer: only one of PasswordFile and PasswordCommand can be set")
+	}
+
+	// a SQLite database is just a file, with no password for the DSN to carry, so a password set for one is a mistake
+	// that should be pointed out, rather than a secret that should be read and then thrown away
+	if (databaseConfig.PasswordFile != "" || databaseConfig.PasswordCommand != "") && databaseConfig.Driver != DriverTypeMySQL {
+		return "", false, errors.New("roamer: PasswordFile and PasswordCommand can only be used with mysql")
+	}
+
+	var data []byte
+	if databaseConfig.PasswordFile != "" {
+		passwordPath := databaseConfig.PasswordFile
+		if !filepath.IsAbs(passwordPath) {
+			passwordPath = filepath.Join(basePath, passwordPath)
+		}
+
+		var err error
+		data, err = os.ReadFile(passwordPath)
+		if err != nil {
+			return "", false, fmt.Errorf("roamer: could not read PasswordFile: %w", err)
+		}
+	} else if databaseConfig.PasswordCommand != "" {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", databaseConfig.PasswordCommand)
+		} else {
+			cmd = exec.Command("sh", "-c", databaseConfig.PasswordCommand)
+		}
+		cmd.Dir = basePath
+
+		// the output is the password, so it's deliberately left out of the error
+		var err error
+		data, err = cmd.Output()
+		if err != nil {
+			return "", false, fmt.Errorf("roamer: PasswordCommand failed: %s", exitReason(err))
+		}
+	} else {
+		return "", false, nil
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+// exitReason describes why a command failed, without including anything it printed.
+func exitReason(err error) string {
+	exitErr, ok := err.(*exec.ExitError)
+	if ok {
+		return exitErr.ProcessState.String()
+	}
+
+	return err.Error()
+}
+
+// A redactedError is an error whose message has had secrets removed from it.
+type redactedError struct {
+	message string
+	inner   error
+}
+
+// Error returns a string representation of the redactedError.
+func (e redactedError) Error() string {
+	return e.message
+}
+
+// Is reports whether the original error of the redactedError matches the target, so that sentinel errors can still be
+// checked for. There is no Unwrap, since that would hand out the original error, secrets and all.
+func (e redactedError) Is(target error) bool {
+	return errors.Is(e.inner, target)
+}
+
+// redactSecrets returns an error like the given one, but with each of the secrets replaced in its message.
+func redactSecrets(err error, secrets []string) error {
+	if err == nil {
+		return nil
+	}
+
+	message := err.Error()
+	redacted := false
+	for _, secret := range secrets {
+		if secret != "" && strings.Contains(message, secret) {
+			message = strings.Replace(message, secret, "[redacted]", -1)
+			redacted = true
+		}
+	}
+
+	if !redacted {
+		return err
+	}
+
+	return redactedError{message, err}
+}
@@ -0,0 +1,112 @@
+package roamer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInterpolateDSN(t *testing.T) {
+	t.Setenv("ROAMER_TEST_USER", "app")
+	t.Setenv("ROAMER_TEST_PASSWORD", "hunter22")
+	t.Setenv("ROAMER_TEST_EMPTY", "")
+
+	dsn, err := interpolateDSN("${ROAMER_TEST_USER}:${ROAMER_TEST_PASSWORD}@tcp(db:3306)/app${ROAMER_TEST_EMPTY}?x=$NOT_A_VARIABLE")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dsn != "app:hunter22@tcp(db:3306)/app?x=$NOT_A_VARIABLE" {
+		t.Errorf("unexpected DSN %s", dsn)
+	}
+
+	_, err = interpolateDSN("${ROAMER_TEST_NOT_SET}")
+	if err == nil || !strings.Contains(err.Error(), "ROAMER_TEST_NOT_SET, which is not set") {
+		t.Errorf("expected an error about the unset variable, got %v", err)
+	}
+}
+
+func TestMySQLDSNPassword(t *testing.T) {
+	tests := []struct {
+		dsn      string
+		expected string
+	}{
+		{"app:hunter22@tcp(db:3306)/app", "hunter22"},
+		{"app:p@ss:w/rd@tcp(db:3306)/app?tls=true", "p@ss:w/rd"},
+		{"app:@tcp(db:3306)/app", ""},
+		{"app@tcp(db:3306)/app", ""},
+		{"/app", ""},
+		{"not a dsn", ""},
+	}
+
+	for _, test := range tests {
+		password := mysqlDSNPassword(test.dsn)
+		if password != test.expected {
+			t.Errorf("mysqlDSNPassword(%q) returned %q, expected %q", test.dsn, password, test.expected)
+		}
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	sentinel := errors.New("connection refused")
+	inner := fmt.Errorf("could not connect as app with password abc to database app: %w", sentinel)
+	err := redactSecrets(inner, []string{"abc", ""})
+
+	// even a short password is removed
+	expected := "could not connect as app with password [redacted] to database app: connection refused"
+	if err.Error() != expected {
+		t.Errorf("got %q, expected %q", err.Error(), expected)
+	}
+	if !errors.Is(err, sentinel) {
+		t.Error("expected the redacted error to still match the sentinel error")
+	}
+	if errors.Unwrap(err) != nil {
+		t.Error("expected the original error, with the password in it, to not be reachable")
+	}
+
+	unchanged := errors.New("connection refused")
+	if redactSecrets(unchanged, []string{"hunter22"}) != unchanged {
+		t.Error("expected an error without secrets to be returned as it is")
+	}
+
+	if redactSecrets(nil, []string{"hunter22"}) != nil {
+		t.Error("expected no error")
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	basePath := t.TempDir()
+	err := os.WriteFile(filepath.Join(basePath, "password.txt"), []byte("from file\r\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	password, hasPassword, err := readPassword(LocalDatabaseConfig{Driver: DriverTypeMySQL, PasswordFile: "password.txt"}, basePath)
+	if err != nil || !hasPassword || password != "from file" {
+		t.Errorf("got %q, %t, %v from PasswordFile", password, hasPassword, err)
+	}
+
+	password, hasPassword, err = readPassword(LocalDatabaseConfig{Driver: DriverTypeMySQL, PasswordCommand: "echo from command"}, basePath)
+	if err != nil || !hasPassword || password != "from command" {
+		t.Errorf("got %q, %t, %v from PasswordCommand", password, hasPassword, err)
+	}
+
+	_, hasPassword, err = readPassword(LocalDatabaseConfig{Driver: DriverTypeMySQL}, basePath)
+	if err != nil || hasPassword {
+		t.Errorf("expected no password, got %t, %v", hasPassword, err)
+	}
+
+	_, _, err = readPassword(LocalDatabaseConfig{Driver: DriverTypeMySQL, PasswordFile: "password.txt", PasswordCommand: "echo x"}, basePath)
+	if err == nil {
+		t.Error("expected an error when both are set")
+	}
+
+	// the command should not even be run for a driver that can't use its output
+	_, _, err = readPassword(LocalDatabaseConfig{Driver: DriverTypeSQLite3, PasswordCommand: "exit 1"}, basePath)
+	if err == nil || !strings.Contains(err.Error(), "can only be used with mysql") {
+		t.Errorf("expected an error about the driver, got %v", err)
+	}
+}
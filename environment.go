@@ -1,15 +1,18 @@
 package roamer
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"path"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-version"
 
@@ -26,6 +29,9 @@ var ErrEnvironmentMissingLocalConfig = errors.New("roamer: environment is missin
 // ErrEnvironmentWasFile is returned when you provide a file as your environment path.
 var ErrEnvironmentWasFile = errors.New("roamer: environment path is a file, not a folder! make sure you provide the *path* to your roamer.toml, not the actual file")
 
+// ErrEnvironmentOffline is returned when something that requires a database connection is attempted in an offline environment.
+var ErrEnvironmentOffline = errors.New("roamer: environment is offline and has no database connection")
+
 // ErrVersionTooOld is returned when the environment requires a newer version of roamer.
 var ErrVersionTooOld = errors.New("roamer: this environment requires a newer version of roamer")
 
@@ -40,14 +46,34 @@ type Environment struct {
 
 	migrations     []Migration
 	migrationsByID map[string]Migration
+	callbackFiles  map[string]bool
+	graph          bool
+
+	database     string
+	stream       string
+	historyTable string
+
+	connectionDriver DriverType
+	connectionDSN    string
+
+	defaultMigrationTimeout time.Duration
 
 	fs         http.FileSystem
+	basePath   string
 	pathOnDisk string
+
+	// localConfigMissing is true if the environment was read from disk without a local config file, so that its driver is only a default.
+	localConfigMissing bool
+
+	logger *slog.Logger
 }
 
+// An EnvironmentOption changes optional settings of an Environment when it is created.
+type EnvironmentOption func(*Environment)
+
 // GetHistoryTableName gets the name of the table roamer is using to track history.
 func (e *Environment) GetHistoryTableName() string {
-	return tableNameRoamerHistory
+	return e.historyTable
 }
 
 func (e *Environment) readFile(filename string) ([]byte, error) {
@@ -65,63 +91,185 @@ func (e *Environment) readFile(filename string) ([]byte, error) {
 }
 
 // NewEnvironment creates a new environment, reading from the given config and http.FileSystem and using the given *sql.DB.
-func NewEnvironment(config Config, localConfig LocalConfig, db *sql.DB, fs http.FileSystem) (*Environment, error) {
+func NewEnvironment(config Config, localConfig LocalConfig, db *sql.DB, fs http.FileSystem, options ...EnvironmentOption) (*Environment, error) {
+	return NewEnvironmentContext(context.Background(), config, localConfig, db, fs, options...)
+}
+
+// NewEnvironmentContext creates a new environment like NewEnvironment, using the given context to check the database connection.
+func NewEnvironmentContext(ctx context.Context, config Config, localConfig LocalConfig, db *sql.DB, fs http.FileSystem, options ...EnvironmentOption) (*Environment, error) {
+	env, err := newEnvironment(config, localConfig, fs, options)
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.connect(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// Connect returns a copy of the environment that uses the given *sql.DB, which must be for the same driver type.
+// The copy shares the migrations that the environment has already read, so it is cheap to make many of them, such as
+// when the same migrations are applied to many databases. The environment itself can be offline.
+func (e *Environment) Connect(db *sql.DB) (*Environment, error) {
+	return e.ConnectContext(context.Background(), db)
+}
+
+// ConnectContext returns a copy of the environment like Connect, using the given context to check the database connection.
+func (e *Environment) ConnectContext(ctx context.Context, db *sql.DB) (*Environment, error) {
+	connected := *e
+
+	err := connected.connect(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &connected, nil
+}
+
+// connect makes the environment use the given *sql.DB, checking that the connection works.
+// If it doesn't, a ConnectionError is returned.
+func (e *Environment) connect(ctx context.Context, db *sql.DB) error {
+	e.db = db
+
+	// test that the db works
+	err := e.db.PingContext(ctx)
+	if err != nil {
+		return ConnectionError{err}
+	}
+
+	// set up the driver
+	if e.LocalConfig.Database.Driver == DriverTypeMySQL {
+		e.driver = &driverMySQL{}
+	} else if e.LocalConfig.Database.Driver == DriverTypeSQLite3 {
+		e.driver = &driverSQLite{}
+	}
+
+	return nil
+}
+
+// NewOfflineEnvironment creates a new environment without a database connection, reading from the given config and http.FileSystem.
+// The driver type in the local config is still used to decide what SQL to generate.
+// Anything that needs to read or change the database will return ErrEnvironmentOffline.
+func NewOfflineEnvironment(config Config, localConfig LocalConfig, fs http.FileSystem, options ...EnvironmentOption) (*Environment, error) {
+	return newEnvironment(config, localConfig, fs, options)
+}
+
+func newEnvironment(config Config, localConfig LocalConfig, fs http.FileSystem, options []EnvironmentOption) (*Environment, error) {
+	env := prepareEnvironment(config, localConfig, fs, options)
+
+	err := env.setUp()
+	if err != nil {
+		return nil, err
+	}
+
+	problems := env.checkSettings()
+	if len(problems) > 0 {
+		return nil, problems[0]
+	}
+
+	problems = env.readMigrations()
+	if len(problems) > 0 {
+		return nil, problems[0]
+	}
+
+	env.logger.Info("roamer: loaded migrations", "count", len(env.migrations), "callbacks", len(env.callbackFiles))
+
+	return &env, nil
+}
+
+// prepareEnvironment creates an environment with the given configs and applies the given options to it.
+func prepareEnvironment(config Config, localConfig LocalConfig, fs http.FileSystem, options []EnvironmentOption) Environment {
 	env := Environment{
 		Config:      config,
 		LocalConfig: localConfig,
 
-		db: db,
-
 		fs: fs,
+
+		logger: slog.New(discardHandler{}),
+	}
+
+	for _, option := range options {
+		option(&env)
 	}
 
-	if env.Config.Environment.MinimumVersion != "" {
+	return env
+}
+
+// setUp selects the database and stream of the environment, which decide where its migrations and history are.
+func (e *Environment) setUp() error {
+	err := e.setUpDatabase()
+	if err != nil {
+		return err
+	}
+
+	return e.setUpStream()
+}
+
+// checkSettings checks the rest of the environment's config, returning every problem it finds.
+func (e *Environment) checkSettings() []error {
+	problems := []error{}
+
+	if e.Config.Environment.MinimumVersion != "" {
 		currentVersion := getVersion()
-		minimumVersion, err := version.NewVersion(env.Config.Environment.MinimumVersion)
+		minimumVersion, err := version.NewVersion(e.Config.Environment.MinimumVersion)
 		if err != nil {
-			return nil, err
+			problems = append(problems, err)
+		} else if minimumVersion.GreaterThan(currentVersion) {
+			problems = append(problems, ErrVersionTooOld)
 		}
+	}
 
-		if minimumVersion.GreaterThan(currentVersion) {
-			return nil, ErrVersionTooOld
+	if e.Config.Environment.MigrationTimeout != "" {
+		migrationTimeout, err := time.ParseDuration(e.Config.Environment.MigrationTimeout)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("roamer: invalid MigrationTimeout: %w", err))
 		}
+
+		e.defaultMigrationTimeout = migrationTimeout
 	}
 
-	var err error
+	if e.LocalConfig.Database.Driver != DriverTypeMySQL && e.LocalConfig.Database.Driver != DriverTypeSQLite3 {
+		problems = append(problems, fmt.Errorf("roamer: did not recognize driver type '%s'", e.LocalConfig.Database.Driver))
+	}
+	lintDriver := e.Config.lintConfig().Driver
+	if lintDriver != "" && lintDriver != DriverTypeMySQL && lintDriver != DriverTypeSQLite3 {
+		problems = append(problems, fmt.Errorf("roamer: did not recognize lint driver type '%s'", lintDriver))
+	}
+	if e.LocalConfig.Database.Driver == DriverTypeSQLite3 && !sqliteAvailable {
+		problems = append(problems, errors.New("roamer: sqlite support not available"))
+	}
 
-	if env.LocalConfig.Database.Driver != DriverTypeMySQL && env.LocalConfig.Database.Driver != DriverTypeSQLite3 {
-		return nil, fmt.Errorf("roamer: did not recognize driver type '%s'", env.LocalConfig.Database.Driver)
+	_, reserved := e.Config.Streams[DefaultName]
+	if reserved {
+		problems = append(problems, fmt.Errorf("roamer: a stream can't be named '%s', since that is the name of the migrations outside of the Streams section", DefaultName))
 	}
-	if env.LocalConfig.Database.Driver == DriverTypeSQLite3 && !sqliteAvailable {
-		return nil, errors.New("roamer: sqlite support not available")
+	_, reserved = e.Config.Databases[DefaultName]
+	if reserved {
+		problems = append(problems, fmt.Errorf("roamer: a database can't be named '%s', since that is the name of the one in the Database section of the local config", DefaultName))
 	}
 
-	// test that the db works
-	err = env.db.Ping()
+	_, err := e.lintSeverities()
 	if err != nil {
-		return nil, err
+		problems = append(problems, err)
 	}
 
-	// set up the driver
-	if env.LocalConfig.Database.Driver == DriverTypeMySQL {
-		env.driver = &driverMySQL{
-			db: env.db,
-		}
-	} else if env.LocalConfig.Database.Driver == DriverTypeSQLite3 {
-		env.driver = &driverSQLite{
-			db: env.db,
-		}
-	}
+	return problems
+}
 
-	// scan the migrations directory
-	migrationsDir, err := fs.Open("")
+// readMigrations scans the migrations directory and reads the migrations in it, returning every problem it finds with them.
+func (e *Environment) readMigrations() []error {
+	migrationsDir, err := e.fs.Open("")
 	if err != nil {
-		return nil, err
+		return []error{err}
 	}
 
 	dirEntries, err := migrationsDir.Readdir(0)
+	migrationsDir.Close()
 	if err != nil {
-		return nil, err
+		return []error{err}
 	}
 
 	filenames := []string{}
@@ -131,161 +279,328 @@ func NewEnvironment(config Config, localConfig LocalConfig, db *sql.DB, fs http.
 
 	sort.Strings(filenames)
 
+	problems := []error{}
+	e.callbackFiles = map[string]bool{}
 	baseNames := []string{}
+	upBaseNames := []string{}
 	for _, filename := range filenames {
-		if strings.HasSuffix(filename, "_down.sql") {
+		if e.IsCallbackFilename(filename) {
+			e.callbackFiles[filename] = true
+			e.logger.Debug("roamer: found callback", "filename", filename)
+		} else if strings.HasSuffix(filename, "_down.sql") {
 			baseName := strings.Replace(filename, "_down.sql", "", -1)
 			baseNames = append(baseNames, baseName)
 		} else if strings.HasSuffix(filename, "_up.sql") {
 			baseName := strings.Replace(filename, "_up.sql", "", -1)
-
-			// we need the matching down migration to exist
-			exists := false
-			for _, existingBaseName := range baseNames {
-				if existingBaseName == baseName {
-					exists = true
-					break
-				}
-			}
-
-			if !exists {
-				return nil, fmt.Errorf("roamer: migration file '%s_down.sql' did not have matching up migration", baseName)
-			}
+			upBaseNames = append(upBaseNames, baseName)
 		} else {
-			return nil, fmt.Errorf("roamer: migration file '%s' did not end in recognized suffixes '_down.sql' or '_up.sql'", filename)
+			problems = append(problems, fmt.Errorf("roamer: migration file '%s' did not end in recognized suffixes '_down.sql' or '_up.sql'", filename))
+		}
+	}
+
+	// every migration needs both of its files
+	hasDown := map[string]bool{}
+	for _, baseName := range baseNames {
+		hasDown[baseName] = true
+	}
+	hasUp := map[string]bool{}
+	for _, baseName := range upBaseNames {
+		hasUp[baseName] = true
+		if !hasDown[baseName] {
+			problems = append(problems, fmt.Errorf("roamer: migration file '%s_up.sql' did not have matching down migration", baseName))
+		}
+	}
+	for _, baseName := range baseNames {
+		if !hasUp[baseName] {
+			problems = append(problems, fmt.Errorf("roamer: migration file '%s_down.sql' did not have matching up migration", baseName))
 		}
 	}
 
-	env.migrations = []Migration{}
-	env.migrationsByID = map[string]Migration{}
-	for i, baseName := range baseNames {
+	e.migrations = []Migration{}
+	e.migrationsByID = map[string]Migration{}
+	for _, baseName := range baseNames {
 		parts := strings.Split(baseName, "_")
 		id := parts[0]
 
-		_, existsAlready := env.migrationsByID[id]
+		_, existsAlready := e.migrationsByID[id]
 		if existsAlready {
-			return nil, fmt.Errorf("roamer: there are two migrations with ID %s", id)
+			problems = append(problems, fmt.Errorf("roamer: there are two migrations with ID %s", id))
+			continue
 		}
 
 		downPath := baseName + "_down.sql"
 		upPath := baseName + "_up.sql"
 
 		// read the description from the down migration
-		downFile, err := env.readFile(downPath)
+		downFile, err := e.readFile(downPath)
 		if err != nil {
-			return nil, err
+			problems = append(problems, err)
+			continue
 		}
 		matches := reMigrationDescription.FindAllSubmatch(downFile, -1)
 		if len(matches) == 0 {
-			return nil, fmt.Errorf("roamer: migration file '%s' is missing a description line", downPath)
+			problems = append(problems, fmt.Errorf("roamer: migration file '%s' is missing a description line", downPath))
+			continue
 		}
 		if len(matches) > 1 {
-			return nil, fmt.Errorf("roamer: migration file '%s' has too many description lines", downPath)
+			problems = append(problems, fmt.Errorf("roamer: migration file '%s' has too many description lines", downPath))
+			continue
 		}
 
 		description := string(matches[0][1])
 
-		env.migrations = append(env.migrations, Migration{
+		e.migrations = append(e.migrations, Migration{
 			ID:          id,
 			Description: description,
 
-			Index: i,
+			Parents: parseParents(downFile),
+
+			Index: len(e.migrations),
 
 			downPath: downPath,
 			upPath:   upPath,
 		})
-		env.migrationsByID[id] = env.migrations[len(env.migrations)-1]
+		e.migrationsByID[id] = e.migrations[len(e.migrations)-1]
+
+		e.logger.Debug("roamer: found migration", "id", id, "description", description)
 	}
 
-	return &env, nil
+	if len(problems) > 0 {
+		// the revision graph can't be trusted if some of the migrations are missing from it
+		return problems
+	}
+
+	err = e.buildRevisionGraph()
+	if err != nil {
+		return []error{err}
+	}
+
+	return nil
 }
 
-// NewEnvironmentFromDisk creates a new environment with the given path.
-func NewEnvironmentFromDisk(basePath string, localConfigName string) (*Environment, error) {
-	// validate the path
+// readConfigsFromDisk reads the config files of the environment at the given path, also returning whether the local config file exists.
+// A missing local config file is not an error, and DefaultLocalConfig is used instead.
+func readConfigsFromDisk(basePath string, localConfigName string) (Config, LocalConfig, bool, error) {
+	err := checkEnvironmentPath(basePath)
+	if err != nil {
+		return Config{}, LocalConfig{}, false, err
+	}
+
+	config, err := readConfigFromDisk(basePath)
+	if err != nil {
+		return Config{}, LocalConfig{}, false, err
+	}
+
+	localConfig, hasLocalConfig, err := readLocalConfigFromDisk(basePath, localConfigName)
+	if err != nil {
+		return Config{}, LocalConfig{}, false, err
+	}
+
+	return config, localConfig, hasLocalConfig, nil
+}
+
+// checkEnvironmentPath checks that the given path is a directory that could be an environment.
+func checkEnvironmentPath(basePath string) error {
 	envInfo, err := os.Stat(basePath)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if !envInfo.IsDir() {
-		return nil, ErrEnvironmentWasFile
+		return ErrEnvironmentWasFile
 	}
 
+	return nil
+}
+
+// readConfigFromDisk reads the roamer.toml file of the environment at the given path.
+func readConfigFromDisk(basePath string) (Config, error) {
 	config := DefaultConfig
-	localConfig := DefaultLocalConfig
+	_, err := decodeConfigFile(basePath, "roamer.toml", &config)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Config{}, ErrEnvironmentMissingConfig
+		}
+
+		return Config{}, err
+	}
 
-	// get the config path and read it
-	configPath := path.Join(basePath, "roamer.toml")
-	configFile, err := os.Open(configPath)
+	return config, nil
+}
+
+// readLocalConfigFromDisk reads the local config file with the given name of the environment at the given path, also
+// returning whether it exists. If it does not, DefaultLocalConfig is returned instead.
+func readLocalConfigFromDisk(basePath string, localConfigName string) (LocalConfig, bool, error) {
+	localConfig := DefaultLocalConfig
+	metadata, err := decodeConfigFile(basePath, "roamer."+localConfigName+".toml", &localConfig)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, ErrEnvironmentMissingConfig
+			return DefaultLocalConfig, false, nil
 		}
 
-		return nil, err
+		return LocalConfig{}, false, err
+	}
+	if !metadata.IsDefined("Database", "DSN") {
+		// the placeholder DSN would otherwise fill in whatever the structured options leave out
+		localConfig.Database.DSN = ""
 	}
-	metadata, err := toml.DecodeReader(configFile, &config)
+
+	return localConfig, true, nil
+}
+
+// decodeConfigFile decodes the config file with the given name, in the environment at the given path, into v.
+func decodeConfigFile(basePath string, filename string, v interface{}) (toml.MetaData, error) {
+	configFile, err := os.Open(path.Join(basePath, filename))
 	if err != nil {
-		return nil, err
+		return toml.MetaData{}, err
+	}
+	defer configFile.Close()
+
+	metadata, err := toml.DecodeReader(configFile, v)
+	if err != nil {
+		return toml.MetaData{}, fmt.Errorf("roamer: could not parse %s: %w", filename, err)
 	}
 	if len(metadata.Undecoded()) != 0 {
-		return nil, UndecodedConfigError{"roamer.toml", metadata.Undecoded()}
+		return toml.MetaData{}, UndecodedConfigError{filename, metadata.Undecoded()}
 	}
 
-	// get the local config path and read it
-	configLocalPath := path.Join(basePath, "roamer."+localConfigName+".toml")
-	configLocalFile, err := os.Open(configLocalPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, ErrEnvironmentMissingLocalConfig
-		}
+	return metadata, nil
+}
 
-		return nil, err
+// openDatabase opens a connection to the database described by the given config, resolving its DSN and password.
+// It also returns the passwords that went into the DSN, which must be kept out of any errors from using the connection.
+func openDatabase(databaseConfig LocalDatabaseConfig, basePath string) (*sql.DB, []string, error) {
+	dsn, secrets, err := resolveDSN(databaseConfig, basePath)
+	if err != nil {
+		return nil, nil, err
 	}
-	metadata, err = toml.DecodeReader(configLocalFile, &localConfig)
+
+	db, err := sql.Open(string(databaseConfig.Driver), dsn)
 	if err != nil {
-		return nil, err
+		return nil, nil, redactSecrets(err, secrets)
 	}
-	if len(metadata.Undecoded()) != 0 {
-		return nil, UndecodedConfigError{"roamer." + localConfigName + ".toml", metadata.Undecoded()}
+
+	return db, secrets, nil
+}
+
+// resolveDSN returns the DSN to connect with for the given config, with its environment variables, password, and
+// structured MySQL options filled in. It also returns the passwords that went into it.
+func resolveDSN(databaseConfig LocalDatabaseConfig, basePath string) (string, []string, error) {
+	dsn, err := interpolateDSN(databaseConfig.DSN)
+	if err != nil {
+		return "", nil, err
 	}
 
-	fullMigrationsPath := path.Join(basePath, config.Environment.MigrationDirectory)
+	secrets := []string{}
+	if databaseConfig.Driver == DriverTypeMySQL {
+		dsnPassword := mysqlDSNPassword(dsn)
+		if dsnPassword != "" {
+			secrets = append(secrets, dsnPassword)
+		}
+	}
 
-	if localConfig.Database.Driver != DriverTypeMySQL && localConfig.Database.Driver != DriverTypeSQLite3 {
-		return nil, fmt.Errorf("roamer: did not recognize driver type '%s'", localConfig.Database.Driver)
+	password, hasPassword, err := readPassword(databaseConfig, basePath)
+	if err != nil {
+		return "", nil, err
 	}
-	if localConfig.Database.Driver == DriverTypeSQLite3 && !sqliteAvailable {
-		return nil, errors.New("roamer: sqlite support not available")
+	if hasPassword {
+		secrets = append(secrets, password)
 	}
 
-	// connect to the db
-	dsn := localConfig.Database.DSN
+	if databaseConfig.Driver == DriverTypeMySQL {
+		config := mysql.NewConfig()
+		if dsn != "" {
+			config, err = mysql.ParseDSN(dsn)
+			if err != nil {
+				return "", nil, redactSecrets(fmt.Errorf("roamer: invalid DSN: %w", err), secrets)
+			}
+		}
 
-	if localConfig.Database.Driver == DriverTypeMySQL {
-		config, err := mysql.ParseDSN(dsn)
+		err = applyMySQLOptions(config, databaseConfig, basePath)
 		if err != nil {
-			return nil, err
+			return "", nil, err
 		}
 
+		if hasPassword {
+			config.Passwd = password
+		}
 		config.MultiStatements = true
 
 		dsn = config.FormatDSN()
+	} else if hasMySQLOptions(databaseConfig) {
+		return "", nil, errors.New("roamer: Host, Port, User, DatabaseName, TLS, CACert, ClientCert, ClientKey, ConnectTimeout, and Charset can only be used with mysql")
 	}
 
-	// try to connect to the database
-	db, err := sql.Open(string(localConfig.Database.Driver), dsn)
+	return dsn, secrets, nil
+}
+
+// NewEnvironmentFromDisk creates a new environment with the given path.
+// The local config file is required, unless a DSN is given with WithConnection.
+func NewEnvironmentFromDisk(basePath string, localConfigName string, options ...EnvironmentOption) (*Environment, error) {
+	config, localConfig, hasLocalConfig, err := readConfigsFromDisk(basePath, localConfigName)
 	if err != nil {
 		return nil, err
 	}
 
-	env, err := NewEnvironment(config, localConfig, db, http.Dir(fullMigrationsPath))
+	env, err := newEnvironment(config, localConfig, nil, withOnDisk(options, basePath))
 	if err != nil {
 		return nil, err
 	}
 
-	env.pathOnDisk = fullMigrationsPath
+	if !hasLocalConfig && env.connectionDSN == "" {
+		return nil, ErrEnvironmentMissingLocalConfig
+	}
+
+	// connect to the db, which might have been chosen by one of the options
+	db, secrets, err := openDatabase(env.LocalConfig.Database, basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.connect(context.Background(), db)
+	if err != nil {
+		return nil, redactSecrets(err, secrets)
+	}
 
 	return env, nil
 }
+
+// NewOfflineEnvironmentFromDisk creates a new environment with the given path, without connecting to the database.
+// The local config file is optional; if it does not exist, the driver type from DefaultLocalConfig is used.
+func NewOfflineEnvironmentFromDisk(basePath string, localConfigName string, options ...EnvironmentOption) (*Environment, error) {
+	config, localConfig, hasLocalConfig, err := readConfigsFromDisk(basePath, localConfigName)
+	if err != nil {
+		return nil, err
+	}
+
+	options = withOnDisk(options, basePath)
+	if !hasLocalConfig {
+		options = append(options, withoutLocalConfig())
+	}
+
+	return NewOfflineEnvironment(config, localConfig, nil, options...)
+}
+
+// CheckMigrationFiles reads the environment at the given path without connecting to a database, like
+// NewOfflineEnvironmentFromDisk, but reports every problem with its config and migration files instead of stopping at
+// the first one. The environment is only returned if there were no problems.
+func CheckMigrationFiles(basePath string, localConfigName string, options ...EnvironmentOption) (*Environment, []error) {
+	config, localConfig, _, err := readConfigsFromDisk(basePath, localConfigName)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	env := prepareEnvironment(config, localConfig, nil, withOnDisk(options, basePath))
+	err = env.setUp()
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	problems := env.checkSettings()
+	problems = append(problems, env.readMigrations()...)
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	return &env, nil
+}
@@ -0,0 +1,52 @@
+package roamer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadMigrationsPairing(t *testing.T) {
+	basePath := writeTestEnvironment(t, "[Environment]\nMigrationDirectory = \"migrations/\"\n", "[Database]\nDriver = \"mysql\"\n", map[string]string{
+		"migrations": "1700000001",
+	})
+
+	files := map[string]string{
+		"1700000002_orphan_up.sql":        "-- Description: Orphan\n",
+		"1700000003_orphan_down.sql":      "-- Description: Orphan\n",
+		"1700000004_first_up.sql":         "-- Description: First\n",
+		"1700000004_first_down.sql":       "-- Description: First\n",
+		"1700000004_second_up.sql":        "-- Description: Second\n",
+		"1700000004_second_down.sql":      "-- Description: Second\n",
+		"1700000005_no_suffix.sql":        "-- Description: No suffix\n",
+		"1700000006_undescribed_up.sql":   "SELECT 1;\n",
+		"1700000006_undescribed_down.sql": "SELECT 1;\n",
+	}
+	for filename, contents := range files {
+		err := os.WriteFile(filepath.Join(basePath, "migrations", filename), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env, problems := CheckMigrationFiles(basePath, "local")
+	if env != nil {
+		t.Error("expected no environment, since there were problems")
+	}
+
+	messages := []string{}
+	for _, problem := range problems {
+		messages = append(messages, problem.Error())
+	}
+	expected := []string{
+		"roamer: migration file '1700000005_no_suffix.sql' did not end in recognized suffixes '_down.sql' or '_up.sql'",
+		"roamer: migration file '1700000002_orphan_up.sql' did not have matching down migration",
+		"roamer: migration file '1700000003_orphan_down.sql' did not have matching up migration",
+		"roamer: there are two migrations with ID 1700000004",
+		"roamer: migration file '1700000006_undescribed_down.sql' is missing a description line",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("got problems\n%v\nexpected\n%v", messages, expected)
+	}
+}
@@ -1,7 +1,10 @@
 package roamer
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -55,3 +58,54 @@ func (e OffsetBoundError) Error() string {
 		e.Input,
 	)
 }
+
+// TimeoutError is reported when a migration or an operation runs for longer than it is allowed to.
+type TimeoutError struct {
+	Timeout time.Duration
+
+	// Operation is true if the timeout was the one set for the whole Operation, rather than for a single migration.
+	Operation bool
+}
+
+// Error returns a string representation of the TimeoutError.
+func (e TimeoutError) Error() string {
+	if e.Operation {
+		return fmt.Sprintf("roamer: operation exceeded its timeout of %s", e.Timeout)
+	}
+
+	return fmt.Sprintf("roamer: migration exceeded its timeout of %s", e.Timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded, so that the TimeoutError can be detected with errors.Is.
+func (e TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// MultipleHeadsError is reported when the revision graph has more than one head, which must be merged before continuing.
+type MultipleHeadsError struct {
+	Heads []string
+}
+
+// Error returns a string representation of the MultipleHeadsError.
+func (e MultipleHeadsError) Error() string {
+	return fmt.Sprintf(
+		"roamer: the revision graph has multiple heads (%s), which must be merged first",
+		strings.Join(e.Heads, ", "),
+	)
+}
+
+// ConnectionError is reported when roamer cannot connect to the database, such as when it can't be reached or when
+// the credentials are wrong.
+type ConnectionError struct {
+	Inner error
+}
+
+// Error returns a string representation of the ConnectionError.
+func (e ConnectionError) Error() string {
+	return fmt.Sprintf("roamer: could not connect to the database: %s", e.Inner.Error())
+}
+
+// Unwrap returns the inner error of the ConnectionError.
+func (e ConnectionError) Unwrap() error {
+	return e.Inner
+}
@@ -0,0 +1,297 @@
+package roamer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// A FleetTarget is one of the databases that UpgradeFleet upgrades.
+type FleetTarget struct {
+	// Name identifies the target in the results and in log messages.
+	Name string
+
+	// DSN is used to connect to the target, using the driver type of the environment.
+	DSN string
+}
+
+// FleetOptions changes how UpgradeFleet upgrades its targets.
+type FleetOptions struct {
+	// Concurrency is how many targets may be upgraded at the same time. If it is less than 1, targets are upgraded one at a time.
+	Concurrency int
+
+	// FailFast stops the run once a target fails. Targets that are already being upgraded are allowed to finish, and the
+	// remaining targets are skipped.
+	FailFast bool
+
+	// Timeout is how long the upgrade of each target may run for, or 0 if there is no limit.
+	Timeout time.Duration
+
+	// TargetEnd is called when each target has been upgraded, failed, or been skipped, if it is not nil.
+	// It is never called by more than one goroutine at a time.
+	TargetEnd func(result FleetResult)
+}
+
+// A FleetResult describes what happened to one of the targets of UpgradeFleet.
+type FleetResult struct {
+	Target FleetTarget
+
+	// Migrations is how many migrations were applied to the target. If the upgrade failed, these are the ones that were
+	// applied before the failure.
+	Migrations int
+
+	// Duration is how long upgrading the target took.
+	Duration time.Duration
+
+	// Skipped is true if the target was not upgraded, because the run was stopped before it started.
+	Skipped bool
+
+	// Err is the error that the upgrade failed with, if any.
+	Err error
+}
+
+// ReadFleetTargets reads a list of targets, one per line, from the given reader.
+// Each line is either a DSN, or a name followed by whitespace and a DSN. Blank lines and lines starting with # are ignored.
+// If template is not empty, each line is instead just a name, and the DSN is made by replacing {name} in the template with it.
+func ReadFleetTargets(r io.Reader, template string) ([]FleetTarget, error) {
+	targets := []FleetTarget{}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if template != "" {
+			if strings.ContainsAny(line, " \t") {
+				return nil, fmt.Errorf("roamer: line %d of the fleet targets has a space in its name", lineNumber)
+			}
+
+			targets = append(targets, FleetTarget{
+				Name: line,
+				DSN:  strings.Replace(template, "{name}", line, -1),
+			})
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("roamer: line %d of the fleet targets has too many fields", lineNumber)
+		}
+
+		target := FleetTarget{
+			DSN: fields[len(fields)-1],
+		}
+		if len(fields) == 2 {
+			target.Name = fields[0]
+		}
+		targets = append(targets, target)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return targets, nil
+}
+
+// UpgradeFleet upgrades every target to the latest migration, sharing the migrations that the environment has read.
+// The environment is only used as a template, so it can be offline. The results are in the same order as the targets.
+//
+// Targets without a name are named after their DSN, with any password removed.
+func (e *Environment) UpgradeFleet(ctx context.Context, targets []FleetTarget, options FleetOptions) []FleetResult {
+	concurrency := options.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]FleetResult, len(targets))
+
+	var mutex sync.Mutex
+	stopped := false
+	finish := func(i int, result FleetResult) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		results[i] = result
+		if result.Err != nil && options.FailFast {
+			stopped = true
+		}
+		if options.TargetEnd != nil {
+			options.TargetEnd(result)
+		}
+	}
+
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		if target.Name == "" {
+			target.Name = redactDSN(e.LocalConfig.Database.Driver, target.DSN)
+		}
+
+		slots <- struct{}{}
+
+		mutex.Lock()
+		skip := stopped
+		mutex.Unlock()
+
+		if skip || ctx.Err() != nil {
+			<-slots
+			finish(i, FleetResult{Target: target, Skipped: true})
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, target FleetTarget) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			finish(i, e.upgradeTarget(ctx, target, options))
+		}(i, target)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// upgradeTarget connects to the given target and upgrades it to the latest migration.
+func (e *Environment) upgradeTarget(ctx context.Context, target FleetTarget, options FleetOptions) FleetResult {
+	result := FleetResult{
+		Target: target,
+	}
+	startTime := time.Now()
+
+	logger := e.logger.With("target", target.Name)
+	logger.Info("roamer: upgrading fleet target")
+
+	result.Migrations, result.Err = e.connectAndUpgrade(ctx, target, options.Timeout, logger)
+	result.Duration = time.Since(startTime)
+
+	if result.Err != nil {
+		logger.Error("roamer: fleet target failed", "migrations", result.Migrations, "duration", result.Duration, "error", result.Err)
+	} else {
+		logger.Info("roamer: upgraded fleet target", "migrations", result.Migrations, "duration", result.Duration)
+	}
+
+	return result
+}
+
+// connectAndUpgrade connects to the given target and upgrades it to the latest migration, returning how many migrations were applied.
+// Like upgrade, it returns the count even if it fails.
+func (e *Environment) connectAndUpgrade(ctx context.Context, target FleetTarget, timeout time.Duration, logger *slog.Logger) (int, error) {
+	databaseConfig := e.targetDatabaseConfig(target)
+
+	db, secrets, err := openDatabase(databaseConfig, e.basePath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	targetEnvironment, err := e.ConnectContext(ctx, db)
+	if err != nil {
+		return 0, redactSecrets(err, secrets)
+	}
+	targetEnvironment.LocalConfig.Database = databaseConfig
+	targetEnvironment.logger = logger
+
+	migrations, err := targetEnvironment.upgrade(ctx, timeout)
+	return migrations, redactSecrets(err, secrets)
+}
+
+// targetDatabaseConfig returns the database config for connecting to the given target. Only the driver is kept from
+// the environment's local config, since its structured options and password describe a different database.
+func (e *Environment) targetDatabaseConfig(target FleetTarget) LocalDatabaseConfig {
+	return LocalDatabaseConfig{
+		Driver: e.LocalConfig.Database.Driver,
+		DSN:    target.DSN,
+	}
+}
+
+// upgrade brings the database up to the latest migration, returning how many migrations were applied, even if it fails
+// partway through.
+func (e *Environment) upgrade(ctx context.Context, timeout time.Duration) (int, error) {
+	err := e.checkHeads()
+	if err != nil {
+		return 0, err
+	}
+
+	report, err := e.VerifySafeToApplyContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !report.OK() {
+		return 0, report.Err()
+	}
+
+	if len(e.migrations) == 0 {
+		return 0, nil
+	}
+	latestMigration := e.migrations[len(e.migrations)-1]
+
+	lastApplied, err := e.GetLastAppliedMigrationContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var from *Migration
+	if lastApplied != nil {
+		migration, err := e.GetMigrationByID(lastApplied.ID)
+		if err != nil {
+			return 0, err
+		}
+
+		from = &migration
+	}
+
+	if from != nil && from.ID == latestMigration.ID && !e.AllowsOutOfOrder() {
+		return 0, nil
+	}
+
+	operation, err := e.NewOperation(from, &latestMigration)
+	if err != nil {
+		return 0, err
+	}
+	if operation.Distance == 0 {
+		return 0, nil
+	}
+
+	operation.Timeout = timeout
+
+	applied := 0
+	operation.Hooks.MigrationEnd = func(m *Migration, d Direction, duration time.Duration) {
+		applied++
+	}
+
+	err = operation.RunContext(ctx)
+	return applied, err
+}
+
+// redactDSN returns the given DSN with its password removed, so that it can be shown to people.
+func redactDSN(driverType DriverType, dsn string) string {
+	if driverType != DriverTypeMySQL {
+		return dsn
+	}
+
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		// don't risk showing a password that couldn't be found
+		return "(invalid DSN)"
+	}
+
+	config.Passwd = ""
+
+	return config.FormatDSN()
+}
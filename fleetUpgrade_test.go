@@ -0,0 +1,171 @@
+//go:build !nocgo
+// +build !nocgo
+
+package roamer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// A fleetTestHandler is a slog.Handler that tracks how many fleet targets are being upgraded at once.
+type fleetTestHandler struct {
+	mutex      *sync.Mutex
+	running    *int
+	maxRunning *int
+}
+
+// Enabled returns true, since every message is needed to track the targets.
+func (h fleetTestHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return true
+}
+
+// Handle updates the number of running targets from the message of the given record.
+func (h fleetTestHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	switch record.Message {
+	case "roamer: upgrading fleet target":
+		*h.running++
+		if *h.running > *h.maxRunning {
+			*h.maxRunning = *h.running
+		}
+	case "roamer: upgraded fleet target", "roamer: fleet target failed":
+		*h.running--
+	}
+
+	return nil
+}
+
+// WithAttrs returns the handler, since the attributes aren't needed.
+func (h fleetTestHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h
+}
+
+// WithGroup returns the handler, since groups aren't needed.
+func (h fleetTestHandler) WithGroup(name string) slog.Handler {
+	return h
+}
+
+// newFleetTestEnvironment creates an offline environment with testMigrationFiles, along with SQLite databases for the
+// given targets. The target named "broken" already has a table named like the index that the second migration creates,
+// so upgrading it fails after the first migration.
+func newFleetTestEnvironment(t *testing.T, names []string) (*Environment, []FleetTarget, *int) {
+	t.Helper()
+
+	directory := t.TempDir()
+	for filename, contents := range testMigrationFiles {
+		err := os.WriteFile(filepath.Join(directory, filename), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := DefaultConfig
+	config.Environment.MinimumVersion = ""
+	localConfig := LocalConfig{Database: LocalDatabaseConfig{Driver: DriverTypeSQLite3}}
+
+	env, err := NewOfflineEnvironment(config, localConfig, http.Dir(directory))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	maxRunning := 0
+	env.logger = slog.New(fleetTestHandler{&sync.Mutex{}, new(int), &maxRunning})
+
+	targets := []FleetTarget{}
+	for _, name := range names {
+		dsn := filepath.Join(t.TempDir(), name+".sqlite")
+		if name == "broken" {
+			db, err := sql.Open("sqlite3", dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = db.Exec("CREATE TABLE users_id (id INT)")
+			db.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		targets = append(targets, FleetTarget{Name: name, DSN: dsn})
+	}
+
+	return env, targets, &maxRunning
+}
+
+// describeFleetResults returns the name and outcome of each result, such as "a ok 2" or "b failed 1".
+func describeFleetResults(results []FleetResult) string {
+	descriptions := []string{}
+	for _, result := range results {
+		outcome := "ok"
+		if result.Skipped {
+			outcome = "skipped"
+		} else if result.Err != nil {
+			outcome = "failed"
+		}
+
+		descriptions = append(descriptions, fmt.Sprintf("%s %s %d", result.Target.Name, outcome, result.Migrations))
+	}
+
+	return strings.Join(descriptions, ", ")
+}
+
+func TestUpgradeFleet(t *testing.T) {
+	env, targets, maxRunning := newFleetTestEnvironment(t, []string{"a", "b", "broken", "c", "d", "e"})
+
+	ended := []string{}
+	results := env.UpgradeFleet(context.Background(), targets, FleetOptions{
+		Concurrency: 2,
+		TargetEnd: func(result FleetResult) {
+			ended = append(ended, result.Target.Name)
+		},
+	})
+
+	// the broken target gets through its first migration, and the others carry on without it
+	expected := "a ok 2, b ok 2, broken failed 1, c ok 2, d ok 2, e ok 2"
+	if describeFleetResults(results) != expected {
+		t.Errorf("got %s, expected %s", describeFleetResults(results), expected)
+	}
+	if !strings.Contains(results[2].Err.Error(), "users_id") {
+		t.Errorf("expected the broken target to fail on the index, got %s", results[2].Err)
+	}
+	if len(ended) != len(targets) {
+		t.Errorf("expected TargetEnd to be called for each target, got %v", ended)
+	}
+	if *maxRunning > 2 {
+		t.Errorf("expected at most 2 targets to be upgraded at once, got %d", *maxRunning)
+	}
+
+	// upgrading again only has the broken target left to do, and it still can't be
+	results = env.UpgradeFleet(context.Background(), targets, FleetOptions{Concurrency: 3})
+	expected = "a ok 0, b ok 0, broken failed 0, c ok 0, d ok 0, e ok 0"
+	if describeFleetResults(results) != expected {
+		t.Errorf("got %s, expected %s", describeFleetResults(results), expected)
+	}
+}
+
+func TestUpgradeFleetFailFast(t *testing.T) {
+	env, targets, _ := newFleetTestEnvironment(t, []string{"a", "broken", "b", "c"})
+
+	results := env.UpgradeFleet(context.Background(), targets, FleetOptions{FailFast: true})
+
+	expected := "a ok 2, broken failed 1, b skipped 0, c skipped 0"
+	if describeFleetResults(results) != expected {
+		t.Errorf("got %s, expected %s", describeFleetResults(results), expected)
+	}
+	for _, result := range results[2:] {
+		if result.Err != nil || result.Duration != 0 {
+			t.Errorf("expected %s to not have been started, got %+v", result.Target.Name, result)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package roamer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadFleetTargets(t *testing.T) {
+	input := `
+# the primary region
+user:pass@tcp(db1:3306)/app
+eu	user:pass@tcp(db2:3306)/app
+
+   asia   user:pass@tcp(db3:3306)/app
+`
+
+	targets, err := ReadFleetTargets(strings.NewReader(input), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []FleetTarget{
+		{DSN: "user:pass@tcp(db1:3306)/app"},
+		{Name: "eu", DSN: "user:pass@tcp(db2:3306)/app"},
+		{Name: "asia", DSN: "user:pass@tcp(db3:3306)/app"},
+	}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("got %+v, expected %+v", targets, expected)
+	}
+}
+
+func TestReadFleetTargetsTemplate(t *testing.T) {
+	targets, err := ReadFleetTargets(strings.NewReader("tenant_a\n# tenant_b\ntenant_c\n"), "user@tcp(db:3306)/{name}?parseTime=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []FleetTarget{
+		{Name: "tenant_a", DSN: "user@tcp(db:3306)/tenant_a?parseTime=true"},
+		{Name: "tenant_c", DSN: "user@tcp(db:3306)/tenant_c?parseTime=true"},
+	}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("got %+v, expected %+v", targets, expected)
+	}
+}
+
+func TestReadFleetTargetsErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		template string
+		expected string
+	}{
+		{"a b c\n", "", "line 1 of the fleet targets has too many fields"},
+		{"\ntenant a\n", "/{name}", "line 2 of the fleet targets has a space in its name"},
+	}
+
+	for _, test := range tests {
+		_, err := ReadFleetTargets(strings.NewReader(test.input), test.template)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("ReadFleetTargets(%q) returned %v, expected an error containing '%s'", test.input, err, test.expected)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package roamer
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrAmbiguousOffset is returned when a relative offset moves forward from a migration that has more than one child.
+var ErrAmbiguousOffset = errors.New("roamer: offset is ambiguous, because the revision graph branches there")
+
+// ErrNothingToMerge is returned when an attempt is made to create a merge migration while there is only one head.
+var ErrNothingToMerge = errors.New("roamer: there is only one head, so there is nothing to merge")
+
+var reMigrationParent = regexp.MustCompile("-- Parent: (.*)\r*\n")
+
+// parseParents returns the IDs listed on the parent lines of a migration file.
+// A file can have several parent lines, and each line can list several IDs, separated by commas.
+func parseParents(migrationData []byte) []string {
+	parents := []string{}
+	for _, match := range reMigrationParent.FindAllSubmatch(migrationData, -1) {
+		for _, parent := range strings.Split(string(match[1]), ",") {
+			parent = strings.TrimSpace(parent)
+			if parent != "" {
+				parents = append(parents, parent)
+			}
+		}
+	}
+
+	return parents
+}
+
+// buildRevisionGraph turns the migrations into a revision graph, if any of them have a parent line.
+// Migrations on disk before the first one with a parent line follow the migration before them, so that a history that
+// started out as a plain list keeps its order without its files being edited, which would change their checksums.
+// Every migration after that must have its own parent line, since guessing from the order on disk is what the graph is
+// there to avoid. The migrations are then put into topological order, with ties broken by their order on disk, and
+// reindexed to match.
+func (e *Environment) buildRevisionGraph() error {
+	for _, migration := range e.migrations {
+		if len(migration.Parents) > 0 {
+			e.graph = true
+			break
+		}
+	}
+
+	if !e.graph {
+		return nil
+	}
+
+	explicit := false
+	for i := range e.migrations {
+		migration := &e.migrations[i]
+		if len(migration.Parents) > 0 {
+			explicit = true
+		} else if explicit {
+			return fmt.Errorf("roamer: migration %s has no parent line, but migrations before it do, so it must say which migration it follows", migration.ID)
+		} else if i > 0 {
+			migration.Parents = []string{e.migrations[i-1].ID}
+		}
+
+		for _, parent := range migration.Parents {
+			_, exists := e.migrationsByID[parent]
+			if !exists {
+				return fmt.Errorf("roamer: migration %s has parent %s, which does not exist", migration.ID, parent)
+			}
+		}
+	}
+
+	sorted := make([]Migration, 0, len(e.migrations))
+	placed := map[string]bool{}
+	remaining := e.migrations
+	for len(remaining) > 0 {
+		next := -1
+		for i, migration := range remaining {
+			allPlaced := true
+			for _, parent := range migration.Parents {
+				if !placed[parent] {
+					allPlaced = false
+					break
+				}
+			}
+
+			if allPlaced {
+				next = i
+				break
+			}
+		}
+
+		if next == -1 {
+			ids := []string{}
+			for _, migration := range remaining {
+				ids = append(ids, migration.ID)
+			}
+
+			return fmt.Errorf("roamer: the parents of migrations %s form a cycle", strings.Join(ids, ", "))
+		}
+
+		migration := remaining[next]
+		migration.Index = len(sorted)
+		sorted = append(sorted, migration)
+		placed[migration.ID] = true
+
+		remaining = append(remaining[:next:next], remaining[next+1:]...)
+	}
+
+	e.migrations = sorted
+	for _, migration := range e.migrations {
+		e.migrationsByID[migration.ID] = migration
+	}
+
+	return nil
+}
+
+// HasRevisionGraph returns true if the migrations form a revision graph, because at least one of them has a parent line.
+func (e *Environment) HasRevisionGraph() bool {
+	return e.graph
+}
+
+// AllowsOutOfOrder returns true if migrations can be applied in a different order than they are on disk, because the
+// environment sets AllowOutOfOrder. A revision graph doesn't allow it by itself, so without AllowOutOfOrder, the
+// migrations must still be applied in the graph's topological order.
+func (e *Environment) AllowsOutOfOrder() bool {
+	return e.Config.Environment.AllowOutOfOrder
+}
+
+// Heads returns the migrations that no other migration follows, in order.
+// Without a revision graph, this is just the last migration on disk.
+func (e *Environment) Heads() []Migration {
+	if !e.graph {
+		if len(e.migrations) == 0 {
+			return []Migration{}
+		}
+
+		return e.migrations[len(e.migrations)-1:]
+	}
+
+	hasChildren := map[string]bool{}
+	for _, migration := range e.migrations {
+		for _, parent := range migration.Parents {
+			hasChildren[parent] = true
+		}
+	}
+
+	heads := []Migration{}
+	for _, migration := range e.migrations {
+		if !hasChildren[migration.ID] {
+			heads = append(heads, migration)
+		}
+	}
+
+	return heads
+}
+
+// checkHeads returns a MultipleHeadsError if the revision graph has more than one head.
+func (e *Environment) checkHeads() error {
+	heads := e.Heads()
+	if len(heads) < 2 {
+		return nil
+	}
+
+	ids := []string{}
+	for _, head := range heads {
+		ids = append(ids, head.ID)
+	}
+
+	return MultipleHeadsError{Heads: ids}
+}
+
+// ancestors returns the IDs of the given migration and every migration it follows, directly or indirectly.
+func (e *Environment) ancestors(id string) map[string]bool {
+	result := map[string]bool{}
+	toVisit := []string{id}
+	for len(toVisit) > 0 {
+		current := toVisit[len(toVisit)-1]
+		toVisit = toVisit[:len(toVisit)-1]
+
+		if result[current] {
+			continue
+		}
+		result[current] = true
+
+		toVisit = append(toVisit, e.migrationsByID[current].Parents...)
+	}
+
+	return result
+}
+
+// children returns the migrations that directly follow the given migration, in order.
+// If the migration is nil, it returns the migrations that have no parents.
+func (e *Environment) children(migration *Migration) []Migration {
+	result := []Migration{}
+	for _, candidate := range e.migrations {
+		if migration == nil {
+			if len(candidate.Parents) == 0 {
+				result = append(result, candidate)
+			}
+			continue
+		}
+
+		for _, parent := range candidate.Parents {
+			if parent == migration.ID {
+				result = append(result, candidate)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// walkRevisionGraph resolves a relative offset by following first parents back, or children forward, from the given migration.
+func (e *Environment) walkRevisionGraph(from *Migration, steps int, idOrOffset string) (*Migration, error) {
+	current := from
+	for ; steps < 0; steps++ {
+		if current == nil {
+			return nil, OffsetBoundError{idOrOffset}
+		}
+
+		if len(current.Parents) == 0 {
+			current = nil
+			continue
+		}
+
+		parent := e.migrationsByID[current.Parents[0]]
+		current = &parent
+	}
+
+	for ; steps > 0; steps-- {
+		children := e.children(current)
+		if len(children) == 0 {
+			return nil, OffsetBoundError{idOrOffset}
+		}
+		if len(children) > 1 {
+			return nil, ErrAmbiguousOffset
+		}
+
+		current = &children[0]
+	}
+
+	return current, nil
+}
+
+// CreateMergeMigration creates a new migration that follows every head of the revision graph, joining them back together.
+func (e *Environment) CreateMergeMigration(description string) error {
+	heads := e.Heads()
+	if len(heads) < 2 {
+		return ErrNothingToMerge
+	}
+
+	parents := []string{}
+	for _, head := range heads {
+		parents = append(parents, head.ID)
+	}
+
+	return e.createMigration(description, parents)
+}
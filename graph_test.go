@@ -0,0 +1,229 @@
+package roamer
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newGraphTestEnvironment creates an offline environment with a migration for each of the given IDs, which follows the
+// parents given for it, if any. The parents are written as they would be in a migration file, such as "1, 2".
+func newGraphTestEnvironment(t *testing.T, config Config, ids []string, parents map[string]string) (*Environment, error) {
+	t.Helper()
+
+	directory := t.TempDir()
+	for _, id := range ids {
+		contents := "-- Description: Migration " + id + "\n"
+		if parents[id] != "" {
+			contents += "-- Parent: " + parents[id] + "\n"
+		}
+		contents += "SELECT 1;\n"
+
+		for _, direction := range []string{"up", "down"} {
+			filename := fmt.Sprintf("%s_migration_%s.sql", id, direction)
+			err := os.WriteFile(filepath.Join(directory, filename), []byte(contents), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	config.Environment.MinimumVersion = ""
+	localConfig := LocalConfig{Database: LocalDatabaseConfig{Driver: DriverTypeMySQL}}
+
+	return NewOfflineEnvironment(config, localConfig, http.Dir(directory))
+}
+
+// migrationIDs returns the IDs of the given migrations, joined with commas.
+func migrationIDs(migrations []Migration) string {
+	ids := []string{}
+	for _, migration := range migrations {
+		ids = append(ids, migration.ID)
+	}
+
+	return strings.Join(ids, ",")
+}
+
+func TestBuildRevisionGraphWithoutParents(t *testing.T) {
+	env, err := newGraphTestEnvironment(t, DefaultConfig, []string{"1", "2", "3"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if env.HasRevisionGraph() {
+		t.Error("expected no revision graph")
+	}
+	if migrationIDs(env.Heads()) != "3" {
+		t.Errorf("expected head 3, got %s", migrationIDs(env.Heads()))
+	}
+}
+
+func TestBuildRevisionGraph(t *testing.T) {
+	// 1 and 2 are from before the graph, then 3 and 4 both branch off from 2, and 5 merges them back together
+	ids := []string{"1", "2", "3", "4", "5"}
+	parents := map[string]string{
+		"3": "2",
+		"4": "2",
+		"5": "4, 3",
+	}
+	env, err := newGraphTestEnvironment(t, DefaultConfig, ids, parents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !env.HasRevisionGraph() {
+		t.Fatal("expected a revision graph")
+	}
+
+	migrations, err := env.ListAllMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrationIDs(migrations) != "1,2,3,4,5" {
+		t.Errorf("unexpected order %s", migrationIDs(migrations))
+	}
+	for i, migration := range migrations {
+		if migration.Index != i {
+			t.Errorf("migration %s has index %d, expected %d", migration.ID, migration.Index, i)
+		}
+	}
+
+	// migrations from before the graph follow the one before them on disk
+	two, err := env.GetMigrationByID("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(two.Parents, ",") != "1" {
+		t.Errorf("expected 2 to follow 1, got %v", two.Parents)
+	}
+
+	if migrationIDs(env.Heads()) != "5" {
+		t.Errorf("expected head 5, got %s", migrationIDs(env.Heads()))
+	}
+
+	// the graph doesn't allow out-of-order migrations on its own
+	if env.AllowsOutOfOrder() {
+		t.Error("expected out-of-order migrations to not be allowed")
+	}
+
+	config := DefaultConfig
+	config.Environment.AllowOutOfOrder = true
+	env, err = newGraphTestEnvironment(t, config, ids, parents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !env.AllowsOutOfOrder() {
+		t.Error("expected out-of-order migrations to be allowed")
+	}
+}
+
+func TestBuildRevisionGraphTopologicalOrder(t *testing.T) {
+	// 2 follows 3, so it has to come after it, even though it's before it on disk
+	env, err := newGraphTestEnvironment(t, DefaultConfig, []string{"1", "2", "3"}, map[string]string{
+		"2": "3",
+		"3": "1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := env.ListAllMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrationIDs(migrations) != "1,3,2" {
+		t.Errorf("unexpected order %s", migrationIDs(migrations))
+	}
+}
+
+func TestBuildRevisionGraphMultipleHeads(t *testing.T) {
+	env, err := newGraphTestEnvironment(t, DefaultConfig, []string{"1", "2", "3"}, map[string]string{
+		"2": "1",
+		"3": "1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if migrationIDs(env.Heads()) != "2,3" {
+		t.Errorf("expected heads 2 and 3, got %s", migrationIDs(env.Heads()))
+	}
+
+	err = env.checkHeads()
+	multipleHeadsErr, ok := err.(MultipleHeadsError)
+	if !ok || strings.Join(multipleHeadsErr.Heads, ",") != "2,3" {
+		t.Errorf("expected a MultipleHeadsError, got %v", err)
+	}
+}
+
+func TestBuildRevisionGraphErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		parents  map[string]string
+		expected string
+	}{
+		{
+			"missing parent line",
+			map[string]string{"2": "1"},
+			"migration 3 has no parent line",
+		},
+		{
+			"parent does not exist",
+			map[string]string{"2": "1", "3": "9"},
+			"migration 3 has parent 9, which does not exist",
+		},
+		{
+			"cycle",
+			map[string]string{"2": "3", "3": "2"},
+			"form a cycle",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := newGraphTestEnvironment(t, DefaultConfig, []string{"1", "2", "3"}, test.parents)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: expected an error containing '%s', got %v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestVerifyOrderWithRevisionGraph(t *testing.T) {
+	ids := []string{"1", "2", "3", "4"}
+	parents := map[string]string{
+		"2": "1",
+		"3": "1",
+		"4": "2, 3",
+	}
+
+	// the branch that comes second on disk was applied first
+	applied := []AppliedMigration{{ID: "1"}, {ID: "3"}}
+
+	env, err := newGraphTestEnvironment(t, DefaultConfig, ids, parents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := env.verifyOrder(applied)
+	if strings.Join(report.IDs(ErrMigrationOutOfOrder), ",") != "3" {
+		t.Errorf("expected 3 to be out of order, got %v", report.Problems)
+	}
+
+	config := DefaultConfig
+	config.Environment.AllowOutOfOrder = true
+	env, err = newGraphTestEnvironment(t, config, ids, parents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report = env.verifyOrder(applied)
+	if !report.OK() {
+		t.Errorf("expected no problems, got %v", report.Problems)
+	}
+
+	// even out of order, nothing can be applied before its parents
+	report = env.verifyOrder([]AppliedMigration{{ID: "1"}, {ID: "3"}, {ID: "4"}})
+	if strings.Join(report.IDs(ErrMigrationParentNotApplied), ",") != "4" {
+		t.Errorf("expected 4 to be missing its parent, got %v", report.Problems)
+	}
+}
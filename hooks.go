@@ -0,0 +1,83 @@
+package roamer
+
+import "time"
+
+// OperationHooks contains functions that are called as an Operation runs, so that its progress can be observed.
+// Any of the functions may be nil.
+type OperationHooks struct {
+	// OperationStart is called when the operation starts running.
+	OperationStart func(o *Operation)
+
+	// OperationEnd is called when the operation finishes, with how long it took and the error it returned, if any.
+	OperationEnd func(o *Operation, duration time.Duration, err error)
+
+	// MigrationStart is called before each migration is applied.
+	MigrationStart func(m *Migration, d Direction)
+
+	// MigrationEnd is called after each migration is successfully applied, with how long it took.
+	MigrationEnd func(m *Migration, d Direction, duration time.Duration)
+
+	// MigrationError is called when a migration fails, with how long it ran for and the error it failed with.
+	MigrationError func(m *Migration, d Direction, duration time.Duration, err error)
+}
+
+// CombineHooks returns an OperationHooks that calls each of the given hooks in order.
+func CombineHooks(hooks ...OperationHooks) OperationHooks {
+	return OperationHooks{
+		OperationStart: func(o *Operation) {
+			for _, h := range hooks {
+				h.operationStart(o)
+			}
+		},
+		OperationEnd: func(o *Operation, duration time.Duration, err error) {
+			for _, h := range hooks {
+				h.operationEnd(o, duration, err)
+			}
+		},
+		MigrationStart: func(m *Migration, d Direction) {
+			for _, h := range hooks {
+				h.migrationStart(m, d)
+			}
+		},
+		MigrationEnd: func(m *Migration, d Direction, duration time.Duration) {
+			for _, h := range hooks {
+				h.migrationEnd(m, d, duration)
+			}
+		},
+		MigrationError: func(m *Migration, d Direction, duration time.Duration, err error) {
+			for _, h := range hooks {
+				h.migrationError(m, d, duration, err)
+			}
+		},
+	}
+}
+
+func (h OperationHooks) operationStart(o *Operation) {
+	if h.OperationStart != nil {
+		h.OperationStart(o)
+	}
+}
+
+func (h OperationHooks) operationEnd(o *Operation, duration time.Duration, err error) {
+	if h.OperationEnd != nil {
+		h.OperationEnd(o, duration, err)
+	}
+}
+
+func (h OperationHooks) migrationStart(m *Migration, d Direction) {
+	if h.MigrationStart != nil {
+		h.MigrationStart(m, d)
+	}
+}
+
+func (h OperationHooks) migrationEnd(m *Migration, d Direction, duration time.Duration) {
+	if h.MigrationEnd != nil {
+		h.MigrationEnd(m, d, duration)
+	}
+}
+
+func (h OperationHooks) migrationError(m *Migration, d Direction, duration time.Duration, err error) {
+	if h.MigrationError != nil {
+		h.MigrationError(m, d, duration, err)
+	}
+}
@@ -0,0 +1,77 @@
+package roamer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingHooks returns hooks that add the given name and the event to calls whenever they're called.
+func recordingHooks(name string, calls *[]string) OperationHooks {
+	return OperationHooks{
+		OperationStart: func(o *Operation) {
+			*calls = append(*calls, name+" operationStart")
+		},
+		OperationEnd: func(o *Operation, duration time.Duration, err error) {
+			*calls = append(*calls, name+" operationEnd "+err.Error())
+		},
+		MigrationStart: func(m *Migration, d Direction) {
+			*calls = append(*calls, name+" migrationStart "+m.ID)
+		},
+		MigrationEnd: func(m *Migration, d Direction, duration time.Duration) {
+			*calls = append(*calls, name+" migrationEnd "+m.ID)
+		},
+		MigrationError: func(m *Migration, d Direction, duration time.Duration, err error) {
+			*calls = append(*calls, name+" migrationError "+m.ID+" "+err.Error())
+		},
+	}
+}
+
+func TestCombineHooks(t *testing.T) {
+	calls := []string{}
+
+	// hooks that are left out entirely or only partly filled in are skipped, rather than being called
+	partial := OperationHooks{
+		MigrationEnd: func(m *Migration, d Direction, duration time.Duration) {
+			calls = append(calls, "partial migrationEnd "+m.ID)
+		},
+	}
+	hooks := CombineHooks(recordingHooks("a", &calls), OperationHooks{}, partial, recordingHooks("b", &calls))
+
+	migration := &Migration{ID: "1"}
+	err := errors.New("failed")
+	hooks.OperationStart(&Operation{})
+	hooks.MigrationStart(migration, DirectionUp)
+	hooks.MigrationEnd(migration, DirectionUp, time.Second)
+	hooks.MigrationError(migration, DirectionUp, time.Second, err)
+	hooks.OperationEnd(&Operation{}, time.Second, err)
+
+	expected := []string{
+		"a operationStart",
+		"b operationStart",
+		"a migrationStart 1",
+		"b migrationStart 1",
+		"a migrationEnd 1",
+		"partial migrationEnd 1",
+		"b migrationEnd 1",
+		"a migrationError 1 failed",
+		"b migrationError 1 failed",
+		"a operationEnd failed",
+		"b operationEnd failed",
+	}
+	if strings.Join(calls, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got calls\n%s\nexpected\n%s", strings.Join(calls, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestCombineHooksEmpty(t *testing.T) {
+	hooks := CombineHooks()
+
+	// every function is set, so callers can use them without checking
+	hooks.OperationStart(&Operation{})
+	hooks.MigrationStart(&Migration{}, DirectionUp)
+	hooks.MigrationEnd(&Migration{}, DirectionUp, 0)
+	hooks.MigrationError(&Migration{}, DirectionUp, 0, nil)
+	hooks.OperationEnd(&Operation{}, 0, nil)
+}
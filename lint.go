@@ -0,0 +1,308 @@
+package roamer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrLintDriverUnknown is returned by Lint when it can't tell which database driver the migrations are written for.
+var ErrLintDriverUnknown = errors.New("roamer: lint needs to know which database driver the migrations are written for")
+
+// A LintSeverity is how serious a problem found by a lint rule is.
+type LintSeverity string
+
+// The severities that a lint rule can have.
+const (
+	LintSeverityError   LintSeverity = "error"
+	LintSeverityWarning LintSeverity = "warning"
+	LintSeverityOff     LintSeverity = "off"
+)
+
+// A LintProblem is something in a migration file that was flagged by a lint rule.
+type LintProblem struct {
+	// Rule is the name of the rule that flagged the problem.
+	Rule     string
+	Severity LintSeverity
+
+	// Filename is the name of the migration file, in the migrations directory.
+	Filename string
+
+	// Line is the line of the statement with the problem, starting from 1.
+	Line    int
+	Message string
+}
+
+// A lintFile is a migration file being checked by the lint rules.
+type lintFile struct {
+	direction  Direction
+	driverType DriverType
+
+	// comments are all of the comments in the file.
+	comments []token
+}
+
+// A lintRule checks each statement of the migration files, returning a message if the statement has a problem.
+type lintRule struct {
+	name            string
+	defaultSeverity LintSeverity
+	check           func(file lintFile, statement sqlStatement) string
+}
+
+// lintRules are the rules that Lint checks, in the order that it checks them.
+var lintRules = []lintRule{
+	{"dropTableWithoutBackup", LintSeverityError, lintDropTableWithoutBackup},
+	{"alterTableWithoutAlgorithm", LintSeverityWarning, lintAlterTableWithoutAlgorithm},
+	{"createIndexNotIdempotent", LintSeverityWarning, lintCreateIndexNotIdempotent},
+}
+
+// lintIgnoreRule is the rule name used for problems with the Lint-Ignore comments themselves.
+const lintIgnoreRule = "lintIgnore"
+
+// lintDropTableWithoutBackup flags DROP TABLE in an up migration, unless the file has a "-- Backup:" line saying where
+// the table's data was backed up. Down migrations are expected to drop the tables that their up migrations create.
+func lintDropTableWithoutBackup(file lintFile, statement sqlStatement) string {
+	if file.direction != DirectionUp || !statement.startsWith("DROP", "TABLE") {
+		return ""
+	}
+
+	for _, comment := range file.comments {
+		note, isBackup := commentDirective(comment, "Backup")
+		if isBackup && note != "" {
+			return ""
+		}
+	}
+
+	return "DROP TABLE in an up migration should have a \"-- Backup:\" line saying where the table's data was backed up"
+}
+
+// lintAlterTableWithoutAlgorithm flags ALTER TABLE on MySQL without ALGORITHM=INPLACE or ALGORITHM=INSTANT.
+// Without one of them, MySQL silently falls back to copying the whole table when it can't change it in place, which
+// blocks writes to a large table for a long time.
+func lintAlterTableWithoutAlgorithm(file lintFile, statement sqlStatement) string {
+	if file.driverType != DriverTypeMySQL || !statement.startsWith("ALTER", "TABLE") {
+		return ""
+	}
+
+	for i, t := range statement.tokens {
+		if !t.isWord("ALGORITHM") {
+			continue
+		}
+
+		next := i + 1
+		if next < len(statement.tokens) && statement.tokens[next].text == "=" {
+			next++
+		}
+		if next < len(statement.tokens) && (statement.tokens[next].isWord("INPLACE") || statement.tokens[next].isWord("INSTANT")) {
+			return ""
+		}
+	}
+
+	return "ALTER TABLE should have ALGORITHM=INPLACE or ALGORITHM=INSTANT, so that it fails instead of copying the whole table"
+}
+
+// lintCreateIndexNotIdempotent flags CREATE INDEX on SQLite without IF NOT EXISTS, which fails if it is run again after
+// a migration fails partway through. MySQL does not support IF NOT EXISTS here, so the rule doesn't apply to it.
+func lintCreateIndexNotIdempotent(file lintFile, statement sqlStatement) string {
+	if file.driverType != DriverTypeSQLite3 || !statement.startsWith("CREATE") {
+		return ""
+	}
+
+	i := 1
+	if i < len(statement.tokens) && (statement.tokens[i].isWord("UNIQUE") || statement.tokens[i].isWord("FULLTEXT") || statement.tokens[i].isWord("SPATIAL")) {
+		i++
+	}
+	if i >= len(statement.tokens) || !statement.tokens[i].isWord("INDEX") {
+		return ""
+	}
+
+	rest := sqlStatement{tokens: statement.tokens[i+1:]}
+	if rest.startsWith("IF", "NOT", "EXISTS") {
+		return ""
+	}
+
+	return "CREATE INDEX should have IF NOT EXISTS, so that the migration can be run again if it fails partway through"
+}
+
+// commentDirective returns the value of the given directive, such as "Backup", if the comment is a "-- Name: value" line.
+func commentDirective(comment token, name string) (string, bool) {
+	if !strings.HasPrefix(comment.text, "--") {
+		return "", false
+	}
+
+	text := strings.TrimSpace(strings.TrimPrefix(comment.text, "--"))
+	if !strings.HasPrefix(text, name+":") {
+		return "", false
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(text, name+":")), true
+}
+
+// lintDriver returns the database driver that the migrations are written for. This comes from the driver given with
+// WithConnection, then the Lint section of the config, and then the local config, if the environment has one.
+func (e *Environment) lintDriver() (DriverType, error) {
+	if e.connectionDriver != "" {
+		return e.connectionDriver, nil
+	}
+	configDriver := e.Config.lintConfig().Driver
+	if configDriver != "" {
+		return configDriver, nil
+	}
+	if !e.localConfigMissing && e.LocalConfig.Database.Driver != "" {
+		return e.LocalConfig.Database.Driver, nil
+	}
+
+	return "", ErrLintDriverUnknown
+}
+
+// lintSeverities returns the severity of each lint rule, after applying the Lint section of the config.
+func (e *Environment) lintSeverities() (map[string]LintSeverity, error) {
+	severities := map[string]LintSeverity{}
+	for _, rule := range lintRules {
+		severities[rule.name] = rule.defaultSeverity
+	}
+
+	// go through the config in order, so that the first problem is always the same one
+	rules := e.Config.lintConfig().Rules
+	names := []string{}
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		severity := rules[name]
+		_, exists := severities[name]
+		if !exists {
+			return nil, fmt.Errorf("roamer: there is no lint rule named '%s'", name)
+		}
+		if severity != LintSeverityError && severity != LintSeverityWarning && severity != LintSeverityOff {
+			return nil, fmt.Errorf("roamer: lint rule '%s' has invalid severity '%s', which must be error, warning, or off", name, severity)
+		}
+
+		severities[name] = severity
+	}
+
+	return severities, nil
+}
+
+// Lint checks the migration files for SQL that is often a mistake, such as dropping a table without noting where its
+// data was backed up. The rules can be turned off, or have their severity changed, in the Lint section of the config.
+//
+// A rule can also be ignored for a single statement with a "-- Lint-Ignore: ruleName" line before it, or for a whole
+// file with a "-- Lint-Ignore-File: ruleName" line. Either can list several rules, separated by commas.
+//
+// Some rules depend on the database driver, so if the environment was read from disk without a local config file, the
+// driver must be given with WithConnection or in the Lint section of the config, or ErrLintDriverUnknown is returned.
+func (e *Environment) Lint() ([]LintProblem, error) {
+	driverType, err := e.lintDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	severities, err := e.lintSeverities()
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []LintProblem{}
+	for _, migration := range e.migrations {
+		for _, direction := range []Direction{DirectionUp, DirectionDown} {
+			filename := migration.downPath
+			if direction == DirectionUp {
+				filename = migration.upPath
+			}
+
+			migrationData, err := e.readMigrationFile(migration, direction)
+			if err != nil {
+				return nil, err
+			}
+
+			fileProblems := lintMigrationFile(filename, string(migrationData), direction, driverType, severities)
+			problems = append(problems, fileProblems...)
+		}
+	}
+
+	return problems, nil
+}
+
+// lintMigrationFile checks a single migration file with every lint rule that isn't turned off.
+func lintMigrationFile(filename string, migrationData string, direction Direction, driverType DriverType, severities map[string]LintSeverity) []LintProblem {
+	tokens := tokenizeSQL(migrationData, driverType)
+
+	file := lintFile{
+		direction:  direction,
+		driverType: driverType,
+	}
+	for _, t := range tokens {
+		if t.kind == tokenComment {
+			file.comments = append(file.comments, t)
+		}
+	}
+
+	problems := []LintProblem{}
+	ignoredInFile := parseLintIgnore(file.comments, "Lint-Ignore-File", filename, &problems)
+
+	for _, statement := range splitStatements(tokens) {
+		ignoredInStatement := parseLintIgnore(statement.comments, "Lint-Ignore", filename, &problems)
+
+		for _, rule := range lintRules {
+			severity := severities[rule.name]
+			if severity == LintSeverityOff || ignoredInFile[rule.name] || ignoredInStatement[rule.name] {
+				continue
+			}
+
+			message := rule.check(file, statement)
+			if message != "" {
+				problems = append(problems, LintProblem{
+					Rule:     rule.name,
+					Severity: severity,
+					Filename: filename,
+					Line:     statement.line,
+					Message:  message,
+				})
+			}
+		}
+	}
+
+	return problems
+}
+
+// parseLintIgnore returns the rules listed by the given directive in the given comments.
+// Any rule that doesn't exist is added to the problems, since it is probably a typo.
+func parseLintIgnore(comments []token, directive string, filename string, problems *[]LintProblem) map[string]bool {
+	ignored := map[string]bool{}
+	for _, comment := range comments {
+		value, isDirective := commentDirective(comment, directive)
+		if !isDirective {
+			continue
+		}
+
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+
+			exists := false
+			for _, rule := range lintRules {
+				if rule.name == name {
+					exists = true
+					break
+				}
+			}
+			if !exists {
+				*problems = append(*problems, LintProblem{
+					Rule:     lintIgnoreRule,
+					Severity: LintSeverityError,
+					Filename: filename,
+					Line:     comment.line,
+					Message:  fmt.Sprintf("%s lists '%s', which is not a lint rule", directive, name),
+				})
+				continue
+			}
+
+			ignored[name] = true
+		}
+	}
+
+	return ignored
+}
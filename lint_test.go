@@ -0,0 +1,179 @@
+package roamer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// defaultLintSeverities returns the default severity of every lint rule.
+func defaultLintSeverities() map[string]LintSeverity {
+	severities := map[string]LintSeverity{}
+	for _, rule := range lintRules {
+		severities[rule.name] = rule.defaultSeverity
+	}
+
+	return severities
+}
+
+// describeLintProblems returns the rule, severity, and line of each problem, such as "createIndexNotIdempotent warning 3".
+func describeLintProblems(problems []LintProblem) []string {
+	result := []string{}
+	for _, problem := range problems {
+		result = append(result, fmt.Sprintf("%s %s %d", problem.Rule, problem.Severity, problem.Line))
+	}
+
+	return result
+}
+
+func TestLintMigrationFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		sql        string
+		direction  Direction
+		driverType DriverType
+		expected   []string
+	}{
+		{
+			"drop table",
+			"-- Description: Drop\nDROP TABLE users;\n",
+			DirectionUp,
+			DriverTypeSQLite3,
+			[]string{"dropTableWithoutBackup error 2"},
+		},
+		{
+			"drop table with backup",
+			"-- Description: Drop\n-- Backup: s3://backups/users.sql\nDROP TABLE users;\n",
+			DirectionUp,
+			DriverTypeSQLite3,
+			[]string{},
+		},
+		{
+			"drop table in down migration",
+			"-- Description: Create\nDROP TABLE users;\n",
+			DirectionDown,
+			DriverTypeSQLite3,
+			[]string{},
+		},
+		{
+			"alter table on mysql",
+			"ALTER TABLE users ADD COLUMN a INT;\nALTER TABLE users ADD COLUMN b INT, ALGORITHM=INSTANT;\nALTER TABLE users ADD COLUMN c INT, ALGORITHM = inplace;\nALTER TABLE users ADD COLUMN d INT, ALGORITHM=COPY;\n",
+			DirectionUp,
+			DriverTypeMySQL,
+			[]string{"alterTableWithoutAlgorithm warning 1", "alterTableWithoutAlgorithm warning 4"},
+		},
+		{
+			"alter table on sqlite",
+			"ALTER TABLE users ADD COLUMN a INT;\n",
+			DirectionUp,
+			DriverTypeSQLite3,
+			[]string{},
+		},
+		{
+			"create index on sqlite",
+			"CREATE INDEX a ON users(a);\nCREATE UNIQUE INDEX b ON users(b);\nCREATE INDEX IF NOT EXISTS c ON users(c);\nCREATE TABLE d (id INT);\n",
+			DirectionUp,
+			DriverTypeSQLite3,
+			[]string{"createIndexNotIdempotent warning 1", "createIndexNotIdempotent warning 2"},
+		},
+		{
+			"create index on mysql",
+			"CREATE INDEX a ON users(a);\n",
+			DirectionUp,
+			DriverTypeMySQL,
+			[]string{},
+		},
+		{
+			"keywords in strings and comments",
+			"-- DROP TABLE users;\nINSERT INTO notes VALUES ('DROP TABLE users');\n/* CREATE INDEX a ON users(a); */\n",
+			DirectionUp,
+			DriverTypeSQLite3,
+			[]string{},
+		},
+		{
+			"ignored for a statement",
+			"-- Lint-Ignore: dropTableWithoutBackup\nDROP TABLE a;\nDROP TABLE b;\n",
+			DirectionUp,
+			DriverTypeSQLite3,
+			[]string{"dropTableWithoutBackup error 3"},
+		},
+		{
+			"ignored for a file",
+			"DROP TABLE a;\n-- Lint-Ignore-File: createIndexNotIdempotent, dropTableWithoutBackup\nDROP TABLE b;\nCREATE INDEX c ON d(e);\n",
+			DirectionUp,
+			DriverTypeSQLite3,
+			[]string{},
+		},
+		{
+			"ignoring a rule that doesn't exist",
+			"-- Lint-Ignore: dropTable\nDROP TABLE a;\n",
+			DirectionUp,
+			DriverTypeSQLite3,
+			[]string{"lintIgnore error 1", "dropTableWithoutBackup error 2"},
+		},
+	}
+
+	for _, test := range tests {
+		problems := lintMigrationFile("migration.sql", test.sql, test.direction, test.driverType, defaultLintSeverities())
+		result := describeLintProblems(problems)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: got %v, expected %v", test.name, result, test.expected)
+		}
+	}
+}
+
+func TestLintMigrationFileSeverities(t *testing.T) {
+	severities := defaultLintSeverities()
+	severities["dropTableWithoutBackup"] = LintSeverityWarning
+	severities["createIndexNotIdempotent"] = LintSeverityOff
+
+	problems := lintMigrationFile("migration.sql", "DROP TABLE a;\nCREATE INDEX b ON c(d);\n", DirectionUp, DriverTypeSQLite3, severities)
+	result := describeLintProblems(problems)
+	expected := []string{"dropTableWithoutBackup warning 1"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("got %v, expected %v", result, expected)
+	}
+}
+
+func TestLintDriver(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      Environment
+		expected DriverType
+	}{
+		{
+			"connection",
+			Environment{connectionDriver: DriverTypeMySQL, Config: Config{Lint: &LintConfig{Driver: DriverTypeSQLite3}}},
+			DriverTypeMySQL,
+		},
+		{
+			"lint config",
+			Environment{Config: Config{Lint: &LintConfig{Driver: DriverTypeMySQL}}, LocalConfig: LocalConfig{Database: LocalDatabaseConfig{Driver: DriverTypeSQLite3}}},
+			DriverTypeMySQL,
+		},
+		{
+			"local config",
+			Environment{LocalConfig: LocalConfig{Database: LocalDatabaseConfig{Driver: DriverTypeMySQL}}},
+			DriverTypeMySQL,
+		},
+		{
+			"missing local config",
+			Environment{LocalConfig: LocalConfig{Database: LocalDatabaseConfig{Driver: DriverTypeSQLite3}}, localConfigMissing: true},
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		driverType, err := test.env.lintDriver()
+		if test.expected == "" {
+			if err != ErrLintDriverUnknown {
+				t.Errorf("%s: expected ErrLintDriverUnknown, got %s, %v", test.name, driverType, err)
+			}
+			continue
+		}
+
+		if err != nil || driverType != test.expected {
+			t.Errorf("%s: got %s, %v, expected %s", test.name, driverType, err, test.expected)
+		}
+	}
+}
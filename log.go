@@ -0,0 +1,27 @@
+package roamer
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithLogger makes the Environment log what it does to the given logger.
+// By default, an Environment does not log anything.
+func WithLogger(logger *slog.Logger) EnvironmentOption {
+	return func(e *Environment) {
+		e.logger = logger
+	}
+}
+
+// Logger returns the logger used by the Environment.
+func (e *Environment) Logger() *slog.Logger {
+	return e.logger
+}
+
+// discardHandler is a slog.Handler that drops everything, used when no logger has been provided.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
@@ -0,0 +1,301 @@
+// Package metrics exposes metrics about roamer environments in the Prometheus text exposition format.
+//
+// A Collector is fed in two ways. Operations report their runs through the hooks returned by Collector.Hooks, and
+// registered environments are read with ListAppliedMigrations whenever the metrics are requested, to report how many
+// migrations are pending and which one was applied last.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+// A Collector keeps track of metrics for one or more named environments.
+// Do not create this struct manually; use the NewCollector function instead.
+type Collector struct {
+	mutex        sync.Mutex
+	environments map[string]*environmentMetrics
+}
+
+type environmentMetrics struct {
+	environment *roamer.Environment
+
+	operationsSucceeded int
+	operationsFailed    int
+	operationSeconds    float64
+
+	migrationsApplied  map[roamer.Direction]int
+	migrationsFailed   map[roamer.Direction]int
+	migrationSeconds   map[roamer.Direction]float64
+	lastOperationEnded time.Time
+}
+
+// NewCollector creates a new, empty Collector.
+func NewCollector() *Collector {
+	return &Collector{
+		environments: map[string]*environmentMetrics{},
+	}
+}
+
+// getEnvironment returns the metrics for the environment with the given name, creating them if needed.
+// The caller must hold the mutex.
+func (c *Collector) getEnvironment(name string) *environmentMetrics {
+	metrics, ok := c.environments[name]
+	if !ok {
+		metrics = &environmentMetrics{
+			migrationsApplied: map[roamer.Direction]int{},
+			migrationsFailed:  map[roamer.Direction]int{},
+			migrationSeconds:  map[roamer.Direction]float64{},
+		}
+		c.environments[name] = metrics
+	}
+
+	return metrics
+}
+
+// Register adds the environment to the Collector under the given name, so that its state is reported.
+func (c *Collector) Register(name string, environment *roamer.Environment) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.getEnvironment(name).environment = environment
+}
+
+// Hooks returns hooks that record the runs of an Operation under the given environment name.
+// They can be combined with other hooks using roamer.CombineHooks.
+func (c *Collector) Hooks(name string) roamer.OperationHooks {
+	return roamer.OperationHooks{
+		OperationEnd: func(o *roamer.Operation, duration time.Duration, err error) {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+
+			metrics := c.getEnvironment(name)
+			if err != nil {
+				metrics.operationsFailed++
+			} else {
+				metrics.operationsSucceeded++
+			}
+			metrics.operationSeconds += duration.Seconds()
+			metrics.lastOperationEnded = time.Now()
+		},
+		MigrationEnd: func(m *roamer.Migration, d roamer.Direction, duration time.Duration) {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+
+			metrics := c.getEnvironment(name)
+			metrics.migrationsApplied[d]++
+			metrics.migrationSeconds[d] += duration.Seconds()
+		},
+		MigrationError: func(m *roamer.Migration, d roamer.Direction, duration time.Duration, err error) {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+
+			metrics := c.getEnvironment(name)
+			metrics.migrationsFailed[d]++
+			metrics.migrationSeconds[d] += duration.Seconds()
+		},
+	}
+}
+
+// ServeHTTP writes the current metrics in the Prometheus text exposition format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteMetrics(r.Context(), w)
+}
+
+// snapshot returns copies of the metrics of every environment, by name, along with the names in alphabetical order.
+// The copies can be read after the mutex is released, so that reading the databases doesn't hold up the hooks.
+func (c *Collector) snapshot() ([]string, map[string]environmentMetrics) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	names := []string{}
+	snapshot := map[string]environmentMetrics{}
+	for name, metrics := range c.environments {
+		names = append(names, name)
+
+		metricsCopy := *metrics
+		metricsCopy.migrationsApplied = maps.Clone(metrics.migrationsApplied)
+		metricsCopy.migrationsFailed = maps.Clone(metrics.migrationsFailed)
+		metricsCopy.migrationSeconds = maps.Clone(metrics.migrationSeconds)
+		snapshot[name] = metricsCopy
+	}
+	sort.Strings(names)
+
+	return names, snapshot
+}
+
+// WriteMetrics writes the current metrics in the Prometheus text exposition format to the given writer.
+// The context is used when reading the state of registered environments from their databases, which is done without
+// holding up the hooks of any operations that are running.
+func (c *Collector) WriteMetrics(ctx context.Context, w io.Writer) {
+	names, snapshot := c.snapshot()
+
+	families := []*family{
+		newFamily("roamer_up", "gauge", "Whether the state of the environment could be read from the database."),
+		newFamily("roamer_migrations", "gauge", "The number of migrations in the migrations directory."),
+		newFamily("roamer_applied_migrations", "gauge", "The number of migrations that have been applied to the database."),
+		newFamily("roamer_pending_migrations", "gauge", "The number of migrations in the migrations directory that have not been applied to the database."),
+		newFamily("roamer_dirty_migrations", "gauge", "The number of applied migrations that are marked as dirty."),
+		newFamily("roamer_last_applied_migration_info", "gauge", "The ID of the last migration that was applied to the database."),
+		newFamily("roamer_last_applied_migration_timestamp_seconds", "gauge", "When the last migration was applied to the database."),
+		newFamily("roamer_operations_total", "counter", "The number of operations that have been run, by result."),
+		newFamily("roamer_operation_duration_seconds", "summary", "How long operations took to run."),
+		newFamily("roamer_last_operation_timestamp_seconds", "gauge", "When the last operation finished running."),
+		newFamily("roamer_migrations_applied_total", "counter", "The number of migrations that have been applied by operations, by direction."),
+		newFamily("roamer_migration_failures_total", "counter", "The number of migrations that have failed while being applied by operations, by direction."),
+		newFamily("roamer_migration_duration_seconds_total", "counter", "The total time spent applying migrations, by direction."),
+	}
+	f := map[string]*family{}
+	for _, family := range families {
+		f[family.name] = family
+	}
+
+	for _, name := range names {
+		metrics := snapshot[name]
+		env := label{"environment", name}
+
+		if metrics.environment != nil {
+			up := 1.0
+			err := writeEnvironmentState(ctx, f, env, metrics.environment)
+			if err != nil {
+				up = 0
+			}
+			f["roamer_up"].add(up, env)
+		}
+
+		f["roamer_operations_total"].add(float64(metrics.operationsSucceeded), env, label{"result", "success"})
+		f["roamer_operations_total"].add(float64(metrics.operationsFailed), env, label{"result", "failure"})
+		f["roamer_operation_duration_seconds"].addSuffixed("_sum", metrics.operationSeconds, env)
+		f["roamer_operation_duration_seconds"].addSuffixed("_count", float64(metrics.operationsSucceeded+metrics.operationsFailed), env)
+		if !metrics.lastOperationEnded.IsZero() {
+			f["roamer_last_operation_timestamp_seconds"].add(float64(metrics.lastOperationEnded.UnixNano())/1e9, env)
+		}
+
+		for _, direction := range []roamer.Direction{roamer.DirectionUp, roamer.DirectionDown} {
+			directionLabel := label{"direction", direction.String()}
+			f["roamer_migrations_applied_total"].add(float64(metrics.migrationsApplied[direction]), env, directionLabel)
+			f["roamer_migration_failures_total"].add(float64(metrics.migrationsFailed[direction]), env, directionLabel)
+			f["roamer_migration_duration_seconds_total"].add(metrics.migrationSeconds[direction], env, directionLabel)
+		}
+	}
+
+	for _, family := range families {
+		family.write(w)
+	}
+}
+
+// writeEnvironmentState adds the current state of the environment, as read from the database, to the metric families.
+func writeEnvironmentState(ctx context.Context, f map[string]*family, env label, environment *roamer.Environment) error {
+	appliedMigrations, err := environment.ListAppliedMigrationsContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	allMigrations, err := environment.ListAllMigrations()
+	if err != nil {
+		return err
+	}
+
+	applied := map[string]bool{}
+	dirty := 0
+	var lastApplied *roamer.AppliedMigration
+	for i, appliedMigration := range appliedMigrations {
+		applied[appliedMigration.ID] = true
+		if appliedMigration.Dirty {
+			dirty++
+		}
+
+		// this matches the ordering used by GetLastAppliedMigration
+		if lastApplied == nil ||
+			appliedMigration.AppliedAt > lastApplied.AppliedAt ||
+			(appliedMigration.AppliedAt == lastApplied.AppliedAt && appliedMigration.ID > lastApplied.ID) {
+			lastApplied = &appliedMigrations[i]
+		}
+	}
+
+	pending := 0
+	for _, migration := range allMigrations {
+		if !applied[migration.ID] {
+			pending++
+		}
+	}
+
+	f["roamer_migrations"].add(float64(len(allMigrations)), env)
+	f["roamer_applied_migrations"].add(float64(len(appliedMigrations)), env)
+	f["roamer_pending_migrations"].add(float64(pending), env)
+	f["roamer_dirty_migrations"].add(float64(dirty), env)
+	if lastApplied != nil {
+		f["roamer_last_applied_migration_info"].add(1, env, label{"id", lastApplied.ID})
+		f["roamer_last_applied_migration_timestamp_seconds"].add(float64(lastApplied.AppliedAt), env)
+	}
+
+	return nil
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+type sample struct {
+	suffix string
+	labels []label
+	value  float64
+}
+
+// A family is a group of samples with the same metric name, written together with its HELP and TYPE lines.
+type family struct {
+	name       string
+	metricType string
+	help       string
+	samples    []sample
+}
+
+func newFamily(name string, metricType string, help string) *family {
+	return &family{
+		name:       name,
+		metricType: metricType,
+		help:       help,
+	}
+}
+
+func (f *family) add(value float64, labels ...label) {
+	f.addSuffixed("", value, labels...)
+}
+
+func (f *family) addSuffixed(suffix string, value float64, labels ...label) {
+	f.samples = append(f.samples, sample{suffix, labels, value})
+}
+
+func (f *family) write(w io.Writer) {
+	if len(f.samples) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.metricType)
+	for _, sample := range f.samples {
+		labelStrings := []string{}
+		for _, label := range sample.labels {
+			labelStrings = append(labelStrings, label.name+"=\""+escapeLabelValue(label.value)+"\"")
+		}
+
+		fmt.Fprintf(w, "%s%s{%s} %v\n", f.name, sample.suffix, strings.Join(labelStrings, ","), sample.value)
+	}
+}
+
+var labelValueReplacer = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
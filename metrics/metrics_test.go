@@ -0,0 +1,214 @@
+//go:build !nocgo
+// +build !nocgo
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thatoddmailbox/roamer"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// newTestEnvironment creates an environment with two migrations, connected to a new in-memory SQLite database, and
+// applies the first of them, as if it was applied at the given time.
+func newTestEnvironment(t *testing.T, appliedAt int) (*roamer.Environment, *sql.DB) {
+	t.Helper()
+
+	directory := t.TempDir()
+	files := map[string]string{
+		"1700000001_create_users_up.sql":   "-- Description: Create users\nCREATE TABLE users (id INT);\n",
+		"1700000001_create_users_down.sql": "-- Description: Create users\nDROP TABLE users;\n",
+		"1700000002_add_index_up.sql":      "-- Description: Add index\nCREATE INDEX users_id ON users(id);\n",
+		"1700000002_add_index_down.sql":    "-- Description: Add index\nDROP INDEX users_id;\n",
+	}
+	for filename, contents := range files {
+		err := os.WriteFile(filepath.Join(directory, filename), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	config := roamer.DefaultConfig
+	config.Environment.MinimumVersion = ""
+	localConfig := roamer.LocalConfig{Database: roamer.LocalDatabaseConfig{Driver: roamer.DriverTypeSQLite3}}
+
+	environment, err := roamer.NewEnvironment(config, localConfig, db, http.Dir(directory))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := environment.ListAllMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operation, err := environment.NewOperation(nil, &migrations[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = operation.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec("UPDATE "+environment.GetHistoryTableName()+" SET appliedAt = ?", appliedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return environment, db
+}
+
+// newTestCollector creates a Collector with a registered environment, one whose database can't be read, and some runs
+// reported through the hooks, with the time of the last operation fixed so that the output is always the same.
+func newTestCollector(t *testing.T) *Collector {
+	t.Helper()
+
+	collector := NewCollector()
+
+	environment, _ := newTestEnvironment(t, 1700000100)
+	collector.Register("main", environment)
+
+	unreadableEnvironment, unreadableDB := newTestEnvironment(t, 1700000100)
+	unreadableDB.Close()
+	collector.Register("unreadable", unreadableEnvironment)
+
+	hooks := collector.Hooks("main")
+	hooks.MigrationEnd(nil, roamer.DirectionUp, 1500*time.Millisecond)
+	hooks.MigrationError(nil, roamer.DirectionUp, 250*time.Millisecond, errors.New("failed"))
+	hooks.OperationEnd(nil, 2*time.Second, nil)
+	hooks.OperationEnd(nil, 500*time.Millisecond, errors.New("failed"))
+
+	collector.Hooks("with \"quotes\"").MigrationEnd(nil, roamer.DirectionDown, time.Second)
+
+	collector.environments["main"].lastOperationEnded = time.Unix(1700000200, 0)
+	collector.environments["with \"quotes\""].lastOperationEnded = time.Unix(1700000300, 0)
+
+	return collector
+}
+
+func TestWriteMetricsGolden(t *testing.T) {
+	collector := newTestCollector(t)
+
+	var output bytes.Buffer
+	collector.WriteMetrics(context.Background(), &output)
+
+	goldenPath := filepath.Join("testdata", "metrics.golden")
+	if *update {
+		err := os.WriteFile(goldenPath, output.Bytes(), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output.String() != string(expected) {
+		t.Errorf("output does not match %s, run with -update if this is expected:\n%s", goldenPath, output.String())
+	}
+}
+
+func TestCollectorServeHTTP(t *testing.T) {
+	collector := newTestCollector(t)
+
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", response.StatusCode)
+	}
+	if !strings.HasPrefix(response.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected Content-Type %s", response.Header.Get("Content-Type"))
+	}
+
+	var body bytes.Buffer
+	_, err = body.ReadFrom(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		`roamer_up{environment="main"} 1`,
+		`roamer_up{environment="unreadable"} 0`,
+		`roamer_pending_migrations{environment="main"} 1`,
+		`roamer_last_applied_migration_info{environment="main",id="1700000001"} 1`,
+		`roamer_operations_total{environment="main",result="failure"} 1`,
+	} {
+		if !strings.Contains(body.String(), line+"\n") {
+			t.Errorf("expected the response to have the line %s", line)
+		}
+	}
+}
+
+func TestCollectorHooksWhileReading(t *testing.T) {
+	collector := NewCollector()
+	environment, db := newTestEnvironment(t, 1700000100)
+	collector.Register("main", environment)
+	hooks := collector.Hooks("main")
+
+	// the database only allows one connection, so holding it makes reading the environment wait
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	written := make(chan struct{})
+	go func() {
+		var output bytes.Buffer
+		collector.WriteMetrics(ctx, &output)
+		close(written)
+	}()
+
+	for db.Stats().WaitCount == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the metrics are now waiting to read the database, which must not make the hooks wait too
+	hooked := make(chan struct{})
+	go func() {
+		hooks.MigrationEnd(nil, roamer.DirectionUp, time.Millisecond)
+		close(hooked)
+	}()
+
+	select {
+	case <-hooked:
+	case <-time.After(5 * time.Second):
+		t.Error("the hooks were blocked while the database was being read")
+	}
+
+	conn.Close()
+	<-written
+}
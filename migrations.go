@@ -1,8 +1,11 @@
 package roamer
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"os"
 	"path"
 	"regexp"
@@ -15,6 +18,7 @@ import (
 var ErrMigrationNotFound = errors.New("roamer: could not find the requested migration")
 
 var reMigrationDescription = regexp.MustCompile("-- Description: (.*)\r*\n")
+var reMigrationTimeout = regexp.MustCompile("(?m)^-- Timeout: (.*?)\r*$")
 var reMultipleUnderscores = regexp.MustCompile("_+")
 
 // A Migration represents a distinct operation performed on a database.
@@ -22,6 +26,9 @@ type Migration struct {
 	ID          string
 	Description string
 
+	// Parents are the IDs of the migrations that this one follows, if the migrations form a revision graph.
+	Parents []string
+
 	Index int
 
 	downPath string
@@ -35,84 +42,272 @@ type AppliedMigration struct {
 	Dirty     bool
 }
 
+// historyUpdateTimeout is how long the history table has to be updated after a migration file has finished, even if the
+// context of the migration was cancelled while it was running.
+const historyUpdateTimeout = 30 * time.Second
+
+const historyTableColumns = `(
+			id VARCHAR(20) PRIMARY KEY,
+			appliedAt INT(11),
+			dirty TINYINT(1),
+			checksum VARCHAR(64)
+			)`
+
+// historyTableSchema returns the statement that creates the history table with the given name.
+func historyTableSchema(table string, ifNotExists bool) string {
+	if ifNotExists {
+		return "CREATE TABLE IF NOT EXISTS " + table + historyTableColumns
+	}
+
+	return "CREATE TABLE " + table + historyTableColumns
+}
+
+// historyStatements returns the statements that update the given history table before and after the given migration is applied.
+// The appliedAt value is either a Unix timestamp or a sqlExpression that evaluates to one.
+func historyStatements(table string, migration Migration, direction Direction, appliedAt interface{}) (statement, statement) {
+	if direction == DirectionUp {
+		return statement{
+			"INSERT INTO " + table + "(id, appliedAt, dirty) VALUES(?, ?, 1)",
+			[]interface{}{migration.ID, appliedAt},
+		}, statement{
+			"UPDATE " + table + " SET dirty = 0 WHERE id = ?",
+			[]interface{}{migration.ID},
+		}
+	}
+
+	return statement{
+		"UPDATE " + table + " SET dirty = 1 WHERE id = ?",
+		[]interface{}{migration.ID},
+	}, statement{
+		"DELETE FROM " + table + " WHERE id = ?",
+		[]interface{}{migration.ID},
+	}
+}
+
+// readMigrationFile reads the file for the given direction of the migration.
+func (e *Environment) readMigrationFile(migration Migration, direction Direction) ([]byte, error) {
+	fileToRead := migration.downPath
+	if direction == DirectionUp {
+		fileToRead = migration.upPath
+	}
+
+	return e.readFile(fileToRead)
+}
+
+// migrationHeader returns the block of comments at the start of the given migration file, which is where directives
+// like the timeout go. It ends at the first line that is neither blank nor a comment.
+func migrationHeader(migrationData []byte) []byte {
+	end := 0
+	for end < len(migrationData) {
+		lineLength := bytes.IndexByte(migrationData[end:], '\n') + 1
+		if lineLength == 0 {
+			lineLength = len(migrationData) - end
+		}
+
+		line := bytes.TrimSpace(migrationData[end : end+lineLength])
+		if len(line) > 0 && !bytes.HasPrefix(line, []byte("--")) {
+			break
+		}
+
+		end += lineLength
+	}
+
+	return migrationData[:end]
+}
+
+// migrationTimeout returns how long the given migration file is allowed to run for, or 0 if there is no limit.
+// This comes from the timeout directive in the file's header, if it has one, and otherwise, from the environment's
+// MigrationTimeout. A directive further down, such as in the SQL itself, is ignored.
+func (e *Environment) migrationTimeout(migrationData []byte) (time.Duration, error) {
+	matches := reMigrationTimeout.FindAllSubmatch(migrationHeader(migrationData), -1)
+	if len(matches) == 0 {
+		return e.defaultMigrationTimeout, nil
+	}
+	if len(matches) > 1 {
+		return 0, errors.New("roamer: migration file has too many timeout lines")
+	}
+
+	timeout, err := time.ParseDuration(strings.TrimSpace(string(matches[0][1])))
+	if err != nil {
+		return 0, fmt.Errorf("roamer: migration file has invalid timeout: %w", err)
+	}
+
+	return timeout, nil
+}
+
 // ApplyMigration applies the migration to the database.
 func (e *Environment) ApplyMigration(migration Migration, direction Direction, stamp bool) error {
-	hasHistoryTable, err := e.driver.TableExists(tableNameRoamerHistory)
+	return e.ApplyMigrationContext(context.Background(), migration, direction, stamp)
+}
+
+// ApplyMigrationContext applies the migration to the database, stopping the migration file if the context is cancelled.
+//
+// The context is used for everything up to and including the migration file itself. Once the migration file has
+// finished, the history table is still updated if the context is cancelled, so that a migration that was applied is
+// reliably marked as applied or removed, but that update is given at most historyUpdateTimeout.
+//
+// If the migration file has a timeout, from its timeout directive or the environment's MigrationTimeout, and runs for
+// longer than that, it is stopped in the same way, and a TimeoutError is returned.
+func (e *Environment) ApplyMigrationContext(ctx context.Context, migration Migration, direction Direction, stamp bool) error {
+	if e.db == nil {
+		return ErrEnvironmentOffline
+	}
+
+	return e.applyMigration(ctx, e.db, migration, direction, stamp, nil, nil)
+}
+
+// applyMigration applies the migration to the database, running everything, including the callbacks and the updates to
+// the history table, on conn. The callbacks may be nil.
+func (e *Environment) applyMigration(ctx context.Context, conn execer, migration Migration, direction Direction, stamp bool, beforeEach *callback, afterEach *callback) error {
+	err := ctx.Err()
 	if err != nil {
 		return err
 	}
 
-	if !hasHistoryTable {
-		// create the history table first
-		_, err := e.db.Exec("CREATE TABLE " + tableNameRoamerHistory + `(
-			id VARCHAR(20) PRIMARY KEY,
-			appliedAt INT(11),
-			dirty TINYINT(1)
-			)`)
+	// read the migration file before changing anything, so that an unreadable file doesn't leave a dirty migration behind
+	var migrationData []byte
+	var timeout time.Duration
+	if !stamp {
+		migrationData, err = e.readMigrationFile(migration, direction)
+		if err != nil {
+			return err
+		}
+
+		timeout, err = e.migrationTimeout(migrationData)
 		if err != nil {
 			return err
 		}
 	}
 
+	// stamping still records the checksum, since it says that the database matches the file
+	checksum := ""
 	if direction == DirectionUp {
-		_, err = e.db.Exec(
-			"INSERT INTO "+tableNameRoamerHistory+"(id, appliedAt, dirty) VALUES(?, ?, 1)",
-			migration.ID,
-			time.Now().Unix(),
-		)
+		upData := migrationData
+		if stamp {
+			upData, err = e.readMigrationFile(migration, direction)
+			if err != nil {
+				return err
+			}
+		}
+
+		checksum = migrationChecksum(upData)
+	}
+
+	err = beforeEach.run(ctx, conn, e.logger)
+	if err != nil {
+		return err
+	}
+
+	hasHistoryTable, err := e.driver.TableExists(ctx, conn, e.historyTable)
+	if err != nil {
+		return err
+	}
+
+	if !hasHistoryTable {
+		// create the history table first
+		_, err := conn.ExecContext(ctx, historyTableSchema(e.historyTable, false))
 		if err != nil {
 			return err
 		}
+
+		e.logger.Info("roamer: created history table", "table", e.historyTable)
 	} else {
-		_, err = e.db.Exec(
-			"UPDATE "+tableNameRoamerHistory+" SET dirty = 1 WHERE id = ?",
-			migration.ID,
-		)
+		err = e.addChecksumColumn(ctx, conn)
 		if err != nil {
 			return err
 		}
 	}
 
+	err = ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	before, after := historyStatements(e.historyTable, migration, direction, time.Now().Unix())
+
+	action := "applying"
+	if stamp {
+		action = "stamping"
+	}
+	logger := e.logger.With("id", migration.ID, "direction", direction.String())
+	logger.Debug("roamer: " + action + " migration")
+	startTime := time.Now()
+
+	_, err = conn.ExecContext(ctx, before.query, before.args...)
+	if err != nil {
+		return err
+	}
+
 	if !stamp {
-		// now read the migration file
-		fileToRead := migration.downPath
-		if direction == DirectionUp {
-			fileToRead = migration.upPath
-		}
-		migrationData, err := e.readFile(fileToRead)
-		if err != nil {
-			return err
+		migrationCtx := ctx
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			migrationCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
 		}
 
-		_, err = e.db.Exec(string(migrationData))
+		_, err = conn.ExecContext(migrationCtx, string(migrationData))
 		if err != nil {
+			if ctx.Err() == nil && migrationCtx.Err() == context.DeadlineExceeded {
+				err = TimeoutError{Timeout: timeout}
+			}
+
+			logger.Error("roamer: migration failed, leaving it marked as dirty", "duration", time.Since(startTime), "error", err)
 			return err
 		}
 	}
 
-	if direction == DirectionUp {
-		_, err = e.db.Exec(
-			"UPDATE "+tableNameRoamerHistory+" SET dirty = 0 WHERE id = ?",
-			migration.ID,
-		)
+	err = afterEach.run(ctx, conn, e.logger)
+	if err != nil {
+		logger.Error("roamer: afterEach callback failed, leaving migration marked as dirty", "duration", time.Since(startTime), "error", err)
+		return err
+	}
+
+	// the migration has been applied, so record that even if the context was cancelled in the meantime
+	historyCtx, cancelHistory := context.WithTimeout(context.WithoutCancel(ctx), historyUpdateTimeout)
+	defer cancelHistory()
+
+	_, err = conn.ExecContext(historyCtx, after.query, after.args...)
+	if err != nil {
+		return err
+	}
+
+	if checksum != "" {
+		checksumUpdate := checksumStatement(e.historyTable, migration, checksum)
+		_, err = conn.ExecContext(historyCtx, checksumUpdate.query, checksumUpdate.args...)
 		if err != nil {
 			return err
 		}
+	}
+
+	if stamp {
+		logger.Info("roamer: stamped migration", "duration", time.Since(startTime))
 	} else {
-		_, err = e.db.Exec(
-			"DELETE FROM "+tableNameRoamerHistory+" WHERE id = ?",
-			migration.ID,
-		)
-		if err != nil {
-			return err
-		}
+		logger.Info("roamer: applied migration", "duration", time.Since(startTime))
 	}
 
 	return nil
 }
 
 // CreateMigration creates a new migration with the given name.
+// If the migrations form a revision graph, the new migration follows the current head, and if there is more than one
+// head, a MultipleHeadsError is returned, as they need to be merged first.
 func (e *Environment) CreateMigration(description string) error {
+	parents := []string{}
+	if e.graph {
+		err := e.checkHeads()
+		if err != nil {
+			return err
+		}
+
+		parents = append(parents, e.Heads()[0].ID)
+	}
+
+	return e.createMigration(description, parents)
+}
+
+// createMigration creates a new migration with the given name, which follows the given parents.
+func (e *Environment) createMigration(description string, parents []string) error {
 	if e.pathOnDisk == "" {
 		return errors.New("roamer: cannot create migration when using an http.FileSystem")
 	}
@@ -128,7 +323,11 @@ func (e *Environment) CreateMigration(description string) error {
 	downPath := path.Join(e.pathOnDisk, id+"_"+normalizedName+"_down.sql")
 	upPath := path.Join(e.pathOnDisk, id+"_"+normalizedName+"_up.sql")
 
-	contents := "-- Description: " + description + "\n-- "
+	contents := "-- Description: " + description + "\n"
+	if len(parents) > 0 {
+		contents += "-- Parent: " + strings.Join(parents, ", ") + "\n"
+	}
+	contents += "-- "
 
 	err := os.WriteFile(downPath, []byte(contents+"Down migration\n\n"), 0664)
 	if err != nil {
@@ -159,7 +358,16 @@ func (e *Environment) ListAllMigrations() ([]Migration, error) {
 
 // ListAppliedMigrations gets all of the migrations that have been applied to the database.
 func (e *Environment) ListAppliedMigrations() ([]AppliedMigration, error) {
-	tableExists, err := e.driver.TableExists(tableNameRoamerHistory)
+	return e.ListAppliedMigrationsContext(context.Background())
+}
+
+// ListAppliedMigrationsContext gets all of the migrations that have been applied to the database, using the given context.
+func (e *Environment) ListAppliedMigrationsContext(ctx context.Context) ([]AppliedMigration, error) {
+	if e.db == nil {
+		return nil, ErrEnvironmentOffline
+	}
+
+	tableExists, err := e.driver.TableExists(ctx, e.db, e.historyTable)
 	if err != nil {
 		return nil, err
 	}
@@ -169,10 +377,11 @@ func (e *Environment) ListAppliedMigrations() ([]AppliedMigration, error) {
 
 	result := []AppliedMigration{}
 
-	rows, err := e.db.Query("SELECT id, appliedAt, dirty FROM " + tableNameRoamerHistory + " ORDER BY id ASC")
+	rows, err := e.db.QueryContext(ctx, "SELECT id, appliedAt, dirty FROM "+e.historyTable+" ORDER BY id ASC")
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		appliedMigration := AppliedMigration{}
@@ -183,12 +392,27 @@ func (e *Environment) ListAppliedMigrations() ([]AppliedMigration, error) {
 		result = append(result, appliedMigration)
 	}
 
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
 
 // GetLastAppliedMigration gets the last migration that has been applied to the database, returning nil if nothing has been applied.
 func (e *Environment) GetLastAppliedMigration() (*AppliedMigration, error) {
-	tableExists, err := e.driver.TableExists(tableNameRoamerHistory)
+	return e.GetLastAppliedMigrationContext(context.Background())
+}
+
+// GetLastAppliedMigrationContext gets the last migration that has been applied to the database, using the given context.
+// It returns nil if nothing has been applied.
+func (e *Environment) GetLastAppliedMigrationContext(ctx context.Context) (*AppliedMigration, error) {
+	if e.db == nil {
+		return nil, ErrEnvironmentOffline
+	}
+
+	tableExists, err := e.driver.TableExists(ctx, e.db, e.historyTable)
 	if err != nil {
 		return nil, err
 	}
@@ -198,8 +422,9 @@ func (e *Environment) GetLastAppliedMigration() (*AppliedMigration, error) {
 
 	result := AppliedMigration{}
 
-	err = e.db.QueryRow(
-		"SELECT id, appliedAt, dirty FROM "+tableNameRoamerHistory+" ORDER BY appliedAt DESC, id DESC LIMIT 1",
+	err = e.db.QueryRowContext(
+		ctx,
+		"SELECT id, appliedAt, dirty FROM "+e.historyTable+" ORDER BY appliedAt DESC, id DESC LIMIT 1",
 	).Scan(&result.ID, &result.AppliedAt, &result.Dirty)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -214,5 +439,14 @@ func (e *Environment) GetLastAppliedMigration() (*AppliedMigration, error) {
 
 // BeginTransaction begins a new transaction, which can then be used to apply migrations.
 func (e *Environment) BeginTransaction() (*sql.Tx, error) {
-	return e.db.Begin()
+	return e.BeginTransactionContext(context.Background())
+}
+
+// BeginTransactionContext begins a new transaction with the given context, which can then be used to apply migrations.
+func (e *Environment) BeginTransactionContext(ctx context.Context) (*sql.Tx, error) {
+	if e.db == nil {
+		return nil, ErrEnvironmentOffline
+	}
+
+	return e.db.BeginTx(ctx, nil)
 }
@@ -0,0 +1,79 @@
+package roamer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrationTimeout(t *testing.T) {
+	env := &Environment{defaultMigrationTimeout: time.Minute}
+
+	tests := []struct {
+		name     string
+		data     string
+		expected time.Duration
+	}{
+		{"directive", "-- Description: Slow\n-- Timeout: 5s\nSELECT 1;\n", 5 * time.Second},
+		{"after blank lines", "-- Description: Slow\n\n-- Timeout: 2m\r\n\nSELECT 1;\n", 2 * time.Minute},
+		{"only comments", "-- Timeout: 5s", 5 * time.Second},
+		{"no directive", "-- Description: Fast\nSELECT 1;\n", time.Minute},
+		{"after the SQL", "-- Description: Fast\nSELECT 1;\n-- Timeout: 5s\n", time.Minute},
+		{"in a string", "-- Description: Fast\nINSERT INTO notes VALUES ('\n-- Timeout: 5s\n');\n", time.Minute},
+		{"not at the start of the line", "-- Description: Fast -- Timeout: 5s\nSELECT 1;\n", time.Minute},
+	}
+
+	for _, test := range tests {
+		timeout, err := env.migrationTimeout([]byte(test.data))
+		if err != nil || timeout != test.expected {
+			t.Errorf("%s: got %s, %v, expected %s", test.name, timeout, err, test.expected)
+		}
+	}
+}
+
+func TestMigrationTimeoutErrors(t *testing.T) {
+	env := &Environment{}
+
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		{"-- Timeout: 5s\n-- Timeout: 10s\nSELECT 1;\n", "too many timeout lines"},
+		{"-- Timeout: soon\nSELECT 1;\n", "invalid timeout"},
+	}
+
+	for _, test := range tests {
+		_, err := env.migrationTimeout([]byte(test.data))
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("with %q, expected an error containing '%s', got %v", test.data, test.expected, err)
+		}
+	}
+}
+
+func TestTimeoutError(t *testing.T) {
+	tests := []struct {
+		err      TimeoutError
+		expected string
+	}{
+		{TimeoutError{Timeout: 5 * time.Second}, "roamer: migration exceeded its timeout of 5s"},
+		{TimeoutError{Timeout: time.Minute, Operation: true}, "roamer: operation exceeded its timeout of 1m0s"},
+	}
+
+	for _, test := range tests {
+		if test.err.Error() != test.expected {
+			t.Errorf("got %q, expected %q", test.err.Error(), test.expected)
+		}
+
+		// code that only knows about contexts should still see a deadline, even through the OperationError
+		err := error(OperationError{Migration: &Migration{ID: "1"}, Inner: test.err})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected %v to match context.DeadlineExceeded", err)
+		}
+		var timeoutErr TimeoutError
+		if !errors.As(err, &timeoutErr) || timeoutErr != test.err {
+			t.Errorf("expected to find %v in the OperationError, got %v", test.err, timeoutErr)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package roamer
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// hasMySQLOptions returns true if any of the structured MySQL options of the given config are set.
+func hasMySQLOptions(databaseConfig LocalDatabaseConfig) bool {
+	return databaseConfig.Host != "" || databaseConfig.Port != 0 || databaseConfig.User != "" ||
+		databaseConfig.DatabaseName != "" || databaseConfig.TLS != "" || databaseConfig.CACert != "" ||
+		databaseConfig.ClientCert != "" || databaseConfig.ClientKey != "" || databaseConfig.ConnectTimeout != "" ||
+		databaseConfig.Charset != ""
+}
+
+// applyMySQLOptions merges the structured MySQL options of the given config into the parsed DSN, replacing anything
+// the DSN already set. Certificate paths are relative to basePath.
+func applyMySQLOptions(config *mysql.Config, databaseConfig LocalDatabaseConfig, basePath string) error {
+	if databaseConfig.Host != "" || databaseConfig.Port != 0 {
+		host := "127.0.0.1"
+		port := "3306"
+		if config.Net == "tcp" && config.Addr != "" {
+			addrHost, addrPort, err := net.SplitHostPort(config.Addr)
+			if err == nil {
+				host = addrHost
+				port = addrPort
+			}
+		}
+
+		if databaseConfig.Host != "" {
+			host = databaseConfig.Host
+		}
+		if databaseConfig.Port != 0 {
+			port = strconv.Itoa(databaseConfig.Port)
+		}
+
+		config.Net = "tcp"
+		config.Addr = net.JoinHostPort(host, port)
+	}
+
+	if databaseConfig.User != "" {
+		config.User = databaseConfig.User
+	}
+	if databaseConfig.DatabaseName != "" {
+		config.DBName = databaseConfig.DatabaseName
+	}
+
+	if databaseConfig.Charset != "" {
+		if config.Params == nil {
+			config.Params = map[string]string{}
+		}
+		config.Params["charset"] = databaseConfig.Charset
+	}
+
+	if databaseConfig.ConnectTimeout != "" {
+		timeout, err := time.ParseDuration(databaseConfig.ConnectTimeout)
+		if err != nil {
+			return fmt.Errorf("roamer: invalid ConnectTimeout: %w", err)
+		}
+
+		config.Timeout = timeout
+	}
+
+	switch databaseConfig.TLS {
+	case "", "true", "false", "skip-verify", "preferred":
+	default:
+		return fmt.Errorf("roamer: invalid TLS mode '%s', which must be true, false, skip-verify, or preferred", databaseConfig.TLS)
+	}
+
+	if databaseConfig.CACert == "" && databaseConfig.ClientCert == "" && databaseConfig.ClientKey == "" {
+		if databaseConfig.TLS != "" {
+			config.TLSConfig = databaseConfig.TLS
+		}
+
+		return nil
+	}
+
+	if databaseConfig.TLS == "false" {
+		return errors.New("roamer: TLS is false, but certificate files are set")
+	}
+	if databaseConfig.TLS == "preferred" {
+		return errors.New("roamer: TLS is preferred, which does not verify the server, but certificate files are set; use true instead")
+	}
+
+	tlsConfig, err := loadTLSConfig(databaseConfig, basePath)
+	if err != nil {
+		return err
+	}
+
+	// the registry is global, so the name depends on the settings, letting environments with the same settings share it
+	hash := sha256.Sum256([]byte(strings.Join([]string{
+		databaseConfig.TLS, databaseConfig.CACert, databaseConfig.ClientCert, databaseConfig.ClientKey, basePath,
+	}, "\x00")))
+	name := "roamer-" + hex.EncodeToString(hash[:8])
+
+	err = mysql.RegisterTLSConfig(name, tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	config.TLSConfig = name
+
+	return nil
+}
+
+// loadTLSConfig reads the certificate files of the given config, relative to basePath, into a tls.Config.
+func loadTLSConfig(databaseConfig LocalDatabaseConfig, basePath string) (*tls.Config, error) {
+	resolvePath := func(filePath string) string {
+		if filepath.IsAbs(filePath) {
+			return filePath
+		}
+
+		return filepath.Join(basePath, filePath)
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: databaseConfig.TLS == "skip-verify",
+	}
+
+	if databaseConfig.CACert != "" {
+		caData, err := os.ReadFile(resolvePath(databaseConfig.CACert))
+		if err != nil {
+			return nil, fmt.Errorf("roamer: could not read CACert: %w", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caData) {
+			return nil, errors.New("roamer: CACert does not contain any PEM certificates")
+		}
+	}
+
+	if databaseConfig.ClientCert != "" || databaseConfig.ClientKey != "" {
+		if databaseConfig.ClientCert == "" || databaseConfig.ClientKey == "" {
+			return nil, errors.New("roamer: ClientCert and ClientKey must be set together")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(resolvePath(databaseConfig.ClientCert), resolvePath(databaseConfig.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("roamer: could not load ClientCert and ClientKey: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,270 @@
+package roamer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// writeTestCertificate writes a new self-signed certificate and its key to cert.pem and key.pem in the given directory.
+func writeTestCertificate(t *testing.T, directory string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "roamer test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyData, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(filepath.Join(directory, "cert.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(directory, "key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyData}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyMySQLOptions(t *testing.T) {
+	config, err := mysql.ParseDSN("user:pass@tcp(db.internal:3307)/app?charset=latin1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = applyMySQLOptions(config, LocalDatabaseConfig{
+		Port:           3308,
+		User:           "migrator",
+		Charset:        "utf8mb4",
+		ConnectTimeout: "5s",
+		TLS:            "preferred",
+	}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// anything that isn't set is kept from the DSN
+	if config.Addr != "db.internal:3308" || config.User != "migrator" || config.Passwd != "pass" || config.DBName != "app" {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if config.Params["charset"] != "utf8mb4" || config.Timeout != 5*time.Second || config.TLSConfig != "preferred" {
+		t.Errorf("unexpected config %+v", config)
+	}
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	basePath := t.TempDir()
+	writeTestCertificate(t, basePath)
+
+	tlsConfig, err := loadTLSConfig(LocalDatabaseConfig{CACert: "cert.pem", ClientCert: "cert.pem", ClientKey: "key.pem"}, basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.RootCAs == nil || len(tlsConfig.Certificates) != 1 {
+		t.Errorf("expected the CA and client certificate to be loaded, got %+v", tlsConfig)
+	}
+	if tlsConfig.InsecureSkipVerify {
+		t.Error("expected the server to be verified")
+	}
+
+	// absolute paths aren't relative to the base path
+	tlsConfig, err = loadTLSConfig(LocalDatabaseConfig{TLS: "skip-verify", CACert: filepath.Join(basePath, "cert.pem")}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tlsConfig.InsecureSkipVerify {
+		t.Error("expected the server to not be verified with skip-verify")
+	}
+}
+
+// handshake makes a TLS connection to a server using the certificate in the given directory, returning any error.
+func handshake(t *testing.T, directory string, clientConfig *tls.Config) error {
+	t.Helper()
+
+	certificate, err := tls.LoadX509KeyPair(filepath.Join(directory, "cert.pem"), filepath.Join(directory, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		conn.(*tls.Conn).Handshake()
+		conn.Close()
+	}()
+
+	clientConfig.ServerName = "127.0.0.1"
+	conn, err := tls.Dial("tcp", listener.Addr().String(), clientConfig)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+func TestLoadTLSConfigVerifiesServer(t *testing.T) {
+	serverPath := t.TempDir()
+	writeTestCertificate(t, serverPath)
+	otherPath := t.TempDir()
+	writeTestCertificate(t, otherPath)
+
+	tlsConfig, err := loadTLSConfig(LocalDatabaseConfig{CACert: "cert.pem"}, serverPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = handshake(t, serverPath, tlsConfig)
+	if err != nil {
+		t.Errorf("expected the server to be trusted, got %s", err)
+	}
+
+	tlsConfig, err = loadTLSConfig(LocalDatabaseConfig{CACert: "cert.pem"}, otherPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = handshake(t, serverPath, tlsConfig)
+	if err == nil {
+		t.Error("expected a server signed by a different CA to be rejected")
+	}
+}
+
+func TestApplyMySQLOptionsTLS(t *testing.T) {
+	basePath := t.TempDir()
+	writeTestCertificate(t, basePath)
+	err := os.WriteFile(filepath.Join(basePath, "empty.pem"), []byte("not a certificate"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := mysql.NewConfig()
+	err = applyMySQLOptions(config, LocalDatabaseConfig{CACert: "cert.pem"}, basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(config.TLSConfig, "roamer-") {
+		t.Errorf("expected a registered TLS config, got '%s'", config.TLSConfig)
+	}
+
+	tests := []struct {
+		databaseConfig LocalDatabaseConfig
+		expected       string
+	}{
+		{LocalDatabaseConfig{TLS: "maybe"}, "invalid TLS mode 'maybe'"},
+		{LocalDatabaseConfig{TLS: "false", CACert: "cert.pem"}, "TLS is false, but certificate files are set"},
+		{LocalDatabaseConfig{TLS: "preferred", CACert: "cert.pem"}, "TLS is preferred"},
+		{LocalDatabaseConfig{ClientCert: "cert.pem"}, "ClientCert and ClientKey must be set together"},
+		{LocalDatabaseConfig{CACert: "empty.pem"}, "CACert does not contain any PEM certificates"},
+		{LocalDatabaseConfig{CACert: "missing.pem"}, "could not read CACert"},
+		{LocalDatabaseConfig{ConnectTimeout: "soon"}, "invalid ConnectTimeout"},
+	}
+
+	for _, test := range tests {
+		err := applyMySQLOptions(mysql.NewConfig(), test.databaseConfig, basePath)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("with %+v, expected an error containing '%s', got %v", test.databaseConfig, test.expected, err)
+		}
+	}
+}
+
+func TestResolveDSNStructuredOptions(t *testing.T) {
+	localConfig := LocalConfig{Database: LocalDatabaseConfig{
+		Driver:       DriverTypeMySQL,
+		DSN:          "user:pass@tcp(db1:3306)/app",
+		Host:         "db2",
+		User:         "migrator",
+		DatabaseName: "primary",
+	}}
+
+	environmentWith := func(options ...EnvironmentOption) *Environment {
+		config := DefaultConfig
+		config.Environment.MinimumVersion = ""
+
+		env, err := NewOfflineEnvironment(config, localConfig, http.Dir(t.TempDir()), options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return env
+	}
+
+	tests := []struct {
+		name           string
+		databaseConfig LocalDatabaseConfig
+		expected       string
+	}{
+		{
+			// the structured options are merged into the DSN they sit next to
+			"local config",
+			environmentWith().LocalConfig.Database,
+			"migrator@db2:3306/primary",
+		},
+		{
+			"connection",
+			environmentWith(WithConnection("", "other:secret@tcp(db3:3306)/other")).LocalConfig.Database,
+			"other@db3:3306/other",
+		},
+		{
+			"fleet target",
+			environmentWith().targetDatabaseConfig(FleetTarget{DSN: "tenant@tcp(db4:3306)/tenant"}),
+			"tenant@db4:3306/tenant",
+		},
+	}
+
+	for _, test := range tests {
+		dsn, _, err := resolveDSN(test.databaseConfig, t.TempDir())
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		config, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := config.User + "@" + config.Addr + "/" + config.DBName
+		if result != test.expected {
+			t.Errorf("%s: connected to %s, expected %s", test.name, result, test.expected)
+		}
+	}
+}
@@ -1,12 +1,22 @@
 package roamer
 
 import (
+	"context"
 	"strconv"
 )
 
 // ResolveIDOrOffset looks up and returns the requested migration.
 // It handles absolute IDs, absolute offsets (such as @2), and relative offsets (such as @+1).
+//
+// If the migrations form a revision graph, absolute offsets count through the migrations in their topological order,
+// and relative offsets walk the graph: going back follows each migration's first parent, and going forward follows its
+// child, returning ErrAmbiguousOffset if there is more than one.
 func (e *Environment) ResolveIDOrOffset(idOrOffset string) (*Migration, error) {
+	return e.ResolveIDOrOffsetContext(context.Background(), idOrOffset)
+}
+
+// ResolveIDOrOffsetContext looks up and returns the requested migration, using the given context to resolve relative offsets.
+func (e *Environment) ResolveIDOrOffsetContext(ctx context.Context, idOrOffset string) (*Migration, error) {
 	if len(idOrOffset) == 0 {
 		return nil, InvalidInputError{idOrOffset}
 	}
@@ -31,7 +41,7 @@ func (e *Environment) ResolveIDOrOffset(idOrOffset string) (*Migration, error) {
 
 		if offsetDetails[0] == '+' || offsetDetails[0] == '-' {
 			// it's a relative offset
-			lastApplied, err := e.GetLastAppliedMigration()
+			lastApplied, err := e.GetLastAppliedMigrationContext(ctx)
 			if err != nil {
 				return nil, err
 			}
@@ -51,6 +61,15 @@ func (e *Environment) ResolveIDOrOffset(idOrOffset string) (*Migration, error) {
 				}
 			}
 
+			if e.graph {
+				var from *Migration
+				if lastAppliedIndex != -1 {
+					from = &allMigrations[lastAppliedIndex]
+				}
+
+				return e.walkRevisionGraph(from, requestedIndex, idOrOffset)
+			}
+
 			requestedIndex = (lastAppliedIndex + 1) + requestedIndex
 		}
 
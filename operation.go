@@ -1,8 +1,10 @@
 package roamer
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 )
 
 // An Operation describes a series of migrations, bringing the database up or down to a new state.
@@ -15,6 +17,16 @@ type Operation struct {
 
 	Stamp bool
 
+	// Timeout is how long the whole operation may run for, or 0 if there is no limit.
+	// This is separate from the timeouts of the individual migrations.
+	Timeout time.Duration
+
+	// Hooks are called as the operation runs, so that its progress can be observed.
+	Hooks OperationHooks
+
+	// PreMigrationCallback is called before each migration is applied.
+	//
+	// Deprecated: use Hooks.MigrationStart instead.
 	PreMigrationCallback func(*Migration, Direction)
 
 	hasRun bool
@@ -22,6 +34,9 @@ type Operation struct {
 	e         *Environment
 	fromIndex int
 	toIndex   int
+
+	// applied contains the IDs of the applied migrations, if the environment allows out-of-order migrations.
+	applied map[string]bool
 }
 
 // An OperationError is returned when there's an error while applying a certain migration.
@@ -87,9 +102,54 @@ func (e *Environment) NewOperation(from *Migration, to *Migration) (*Operation,
 		o.Distance = -1 * o.Distance
 	}
 
+	if e.AllowsOutOfOrder() && e.db != nil {
+		// migrations before the target might not have been applied yet, so staying in place still goes up
+		if o.toIndex == o.fromIndex {
+			o.Direction = DirectionUp
+		}
+
+		err := o.loadApplied(context.Background())
+		if err != nil {
+			return nil, err
+		}
+
+		if e.graph {
+			// the target might be on a different branch than the last applied migration,
+			// so go up if anything it needs is missing, and otherwise, go down
+			o.Direction = DirectionDown
+			for id := range o.targetMigrations() {
+				if !o.applied[id] {
+					o.Direction = DirectionUp
+					break
+				}
+			}
+		}
+
+		o.Distance = len(o.migrationsToApply())
+	}
+
 	return &o, nil
 }
 
+// loadApplied reads which migrations have been applied, if the environment allows out-of-order migrations.
+func (o *Operation) loadApplied(ctx context.Context) error {
+	if !o.e.AllowsOutOfOrder() || o.e.db == nil {
+		return nil
+	}
+
+	appliedMigrations, err := o.e.ListAppliedMigrationsContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	o.applied = map[string]bool{}
+	for _, appliedMigration := range appliedMigrations {
+		o.applied[appliedMigration.ID] = true
+	}
+
+	return nil
+}
+
 // DistanceString returns a string repesentation of the distance spanned by the Operation.
 func (o *Operation) DistanceString() string {
 	plural := ""
@@ -105,13 +165,9 @@ func (o *Operation) DistanceString() string {
 	)
 }
 
-// Run runs the given operation.
-func (o *Operation) Run() error {
-	if o.hasRun {
-		return errors.New("roamer: operation has already been run")
-	}
-
-	lastApplied, err := o.e.GetLastAppliedMigration()
+// checkFrom checks that the From migration of the operation matches the last migration applied to the database.
+func (o *Operation) checkFrom(ctx context.Context) error {
+	lastApplied, err := o.e.GetLastAppliedMigrationContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -126,29 +182,213 @@ func (o *Operation) Run() error {
 		}
 	}
 
-	o.hasRun = true
+	return nil
+}
+
+// migrationsToApply returns the migrations that the operation will apply, in the order they will be applied.
+func (o *Operation) migrationsToApply() []Migration {
+	if o.applied != nil {
+		return o.outOfOrderMigrationsToApply()
+	}
 
 	offset := 0
 	if o.Direction == DirectionUp {
 		offset = 1
 	}
 
+	result := []Migration{}
 	for i := o.fromIndex; i != o.toIndex; i += int(o.Direction) {
-		migrationToApply := o.e.migrations[i+offset]
+		result = append(result, o.e.migrations[i+offset])
+	}
+
+	return result
+}
+
+// outOfOrderMigrationsToApply returns the migrations that the operation will apply, when out-of-order migrations are allowed.
+// Going up, this is every migration that To needs that has not been applied. Going down, this is every applied
+// migration that To does not need, starting with the last one on disk.
+func (o *Operation) outOfOrderMigrationsToApply() []Migration {
+	target := o.targetMigrations()
+
+	result := []Migration{}
+	if o.Direction == DirectionUp {
+		for _, migration := range o.e.migrations {
+			if target[migration.ID] && !o.applied[migration.ID] {
+				result = append(result, migration)
+			}
+		}
+	} else {
+		for i := len(o.e.migrations) - 1; i >= 0; i-- {
+			migration := o.e.migrations[i]
+			if !target[migration.ID] && o.applied[migration.ID] {
+				result = append(result, migration)
+			}
+		}
+	}
+
+	return result
+}
+
+// targetMigrations returns the IDs of the migrations that should be applied once the operation is done.
+// With a revision graph, this is To and its ancestors, and otherwise, it is To and every migration before it on disk.
+func (o *Operation) targetMigrations() map[string]bool {
+	if o.e.graph {
+		if o.To == nil {
+			return map[string]bool{}
+		}
+
+		return o.e.ancestors(o.To.ID)
+	}
+
+	result := map[string]bool{}
+	for i := 0; i <= o.toIndex; i++ {
+		result[o.e.migrations[i].ID] = true
+	}
+
+	return result
+}
+
+// Run runs the given operation.
+func (o *Operation) Run() error {
+	return o.RunContext(context.Background())
+}
+
+// RunContext runs the given operation, stopping early if the context is cancelled.
+//
+// If the context is cancelled between migrations, the context's error is returned, and the migrations applied so far
+// are left in place. If it is cancelled while a migration file is running, an OperationError is returned, and that
+// migration is left marked as dirty. See ApplyMigrationContext for details.
+//
+// Running out of time, either for the whole operation or for a single migration, is handled in the same way, with a
+// TimeoutError being returned, or being wrapped in the OperationError for the migration that was running.
+//
+// If the migrations form a revision graph with more than one head, nothing is run, and a MultipleHeadsError is returned.
+func (o *Operation) RunContext(ctx context.Context) error {
+	if o.hasRun {
+		return errors.New("roamer: operation has already been run")
+	}
+
+	fromID := ""
+	if o.From != nil {
+		fromID = o.From.ID
+	}
+	toID := ""
+	if o.To != nil {
+		toID = o.To.ID
+	}
+	logger := o.e.logger.With("from", fromID, "to", toID)
+
+	logger.Info("roamer: running operation", "migrations", o.DistanceString(), "stamp", o.Stamp)
+	startTime := time.Now()
+	o.Hooks.operationStart(o)
+
+	err := o.run(ctx)
+
+	duration := time.Since(startTime)
+	o.Hooks.operationEnd(o, duration, err)
+
+	if err != nil {
+		logger.Error("roamer: operation failed", "duration", duration, "error", err)
+	} else {
+		logger.Info("roamer: finished operation", "duration", duration)
+	}
+
+	return err
+}
+
+func (o *Operation) run(ctx context.Context) error {
+	parentCtx := ctx
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+
+	err := o.e.checkHeads()
+	if err != nil {
+		return err
+	}
+
+	err = o.checkFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = o.loadApplied(ctx)
+	if err != nil {
+		return err
+	}
+
+	o.hasRun = true
+
+	callbacks := operationCallbacks{}
+	if !o.Stamp {
+		callbacks, err = o.e.readCallbacks(o.Direction)
+		if err != nil {
+			return err
+		}
+	}
+
+	// everything runs on the same connection, so that callbacks can change settings for the whole session
+	connStartTime := time.Now()
+	conn, err := o.e.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	o.e.logger.Debug("roamer: got database connection", "wait", time.Since(connStartTime))
+
+	err = callbacks.beforeAll.run(ctx, conn, o.e.logger)
+	if err != nil {
+		return o.timeoutError(parentCtx, ctx, err)
+	}
 
+	for _, migrationToApply := range o.migrationsToApply() {
+		migrationToApply := migrationToApply
+
+		err := ctx.Err()
+		if err != nil {
+			return o.timeoutError(parentCtx, ctx, err)
+		}
+
+		o.Hooks.migrationStart(&migrationToApply, o.Direction)
 		if o.PreMigrationCallback != nil {
 			o.PreMigrationCallback(&migrationToApply, o.Direction)
 		}
 
-		err := o.e.ApplyMigration(migrationToApply, o.Direction, o.Stamp)
+		migrationStartTime := time.Now()
+		err = o.e.applyMigration(ctx, conn, migrationToApply, o.Direction, o.Stamp, callbacks.beforeEach, callbacks.afterEach)
+		migrationDuration := time.Since(migrationStartTime)
 		if err != nil {
 			// the migration failed!
+			err = o.timeoutError(parentCtx, ctx, err)
+			o.Hooks.migrationError(&migrationToApply, o.Direction, migrationDuration, err)
+
 			return OperationError{
 				Migration: &migrationToApply,
 				Inner:     err,
 			}
 		}
+
+		o.Hooks.migrationEnd(&migrationToApply, o.Direction, migrationDuration)
+	}
+
+	err = callbacks.afterAll.run(ctx, conn, o.e.logger)
+	if err != nil {
+		return o.timeoutError(parentCtx, ctx, err)
 	}
 
 	return nil
 }
+
+// timeoutError replaces the given error with a TimeoutError if the operation's own timeout has run out.
+func (o *Operation) timeoutError(parentCtx context.Context, ctx context.Context, err error) error {
+	if parentCtx.Err() == nil && ctx.Err() == context.DeadlineExceeded {
+		return TimeoutError{
+			Timeout:   o.Timeout,
+			Operation: true,
+		}
+	}
+
+	return err
+}
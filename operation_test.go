@@ -0,0 +1,223 @@
+//go:build !nocgo
+// +build !nocgo
+
+package roamer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestEnvironment creates an environment with the given migration files, connected to a new in-memory SQLite
+// database that only allows a single connection, like many SQLite setups do.
+func newTestEnvironment(t *testing.T, files map[string]string) *Environment {
+	t.Helper()
+
+	directory := t.TempDir()
+	for filename, contents := range files {
+		err := os.WriteFile(filepath.Join(directory, filename), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	config := DefaultConfig
+	config.Environment.MinimumVersion = ""
+	localConfig := LocalConfig{Database: LocalDatabaseConfig{Driver: DriverTypeSQLite3}}
+
+	env, err := NewEnvironment(config, localConfig, db, http.Dir(directory))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return env
+}
+
+var testMigrationFiles = map[string]string{
+	"1700000001_create_users_up.sql":   "-- Description: Create users\nCREATE TABLE users (id INT);\n",
+	"1700000001_create_users_down.sql": "-- Description: Create users\nDROP TABLE users;\n",
+	"1700000002_add_index_up.sql":      "-- Description: Add index\nCREATE INDEX users_id ON users(id);\n",
+	"1700000002_add_index_down.sql":    "-- Description: Add index\nDROP INDEX users_id;\n",
+	"beforeAll_up.sql":                 "CREATE TEMPORARY TABLE session_marker (id INT);\n",
+}
+
+func TestOperationUsesSingleConnection(t *testing.T) {
+	env := newTestEnvironment(t, testMigrationFiles)
+
+	migrations, err := env.ListAllMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operation, err := env.NewOperation(nil, &migrations[len(migrations)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// if anything in the operation went around the connection that it holds, this would never finish
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = operation.RunContext(ctx)
+	if err != nil {
+		t.Fatalf("operation failed: %s", err)
+	}
+
+	applied, err := env.ListAppliedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 {
+		t.Fatalf("expected 2 applied migrations, got %d", len(applied))
+	}
+	for _, appliedMigration := range applied {
+		if appliedMigration.Dirty {
+			t.Errorf("migration %s was left dirty", appliedMigration.ID)
+		}
+	}
+
+	report, err := env.VerifyChecksums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("expected checksums to match, got %v", report.Problems)
+	}
+
+	checksums, err := env.appliedChecksums(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checksums) != 2 {
+		t.Errorf("expected 2 recorded checksums, got %d", len(checksums))
+	}
+}
+
+func TestOperationMigrationTimeout(t *testing.T) {
+	env := newTestEnvironment(t, map[string]string{
+		"1700000001_create_users_up.sql":    "-- Description: Create users\nCREATE TABLE users (id INT);\n",
+		"1700000001_create_users_down.sql":  "-- Description: Create users\nDROP TABLE users;\n",
+		"1700000002_count_forever_up.sql":   "-- Description: Count forever\n-- Timeout: 100ms\nWITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT count(*) FROM c;\n",
+		"1700000002_count_forever_down.sql": "-- Description: Count forever\nSELECT 1;\n",
+	})
+
+	migrations, err := env.ListAllMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	operation, err := env.NewOperation(nil, &migrations[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	startTime := time.Now()
+	err = operation.Run()
+	if time.Since(startTime) > 10*time.Second {
+		t.Errorf("expected the migration to be stopped after its timeout, but it took %s", time.Since(startTime))
+	}
+
+	var timeoutErr TimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Timeout != 100*time.Millisecond || timeoutErr.Operation {
+		t.Fatalf("expected a TimeoutError for the migration, got %v", err)
+	}
+	operationErr, ok := err.(OperationError)
+	if !ok || operationErr.Migration.ID != "1700000002" {
+		t.Errorf("expected the second migration to fail, got %v", err)
+	}
+
+	// the first migration isn't affected, and the one that was stopped is left dirty, since it might have done some of its work
+	applied, err := env.ListAppliedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[0].Dirty || !applied[1].Dirty {
+		t.Errorf("expected the first migration to be applied and the second to be dirty, got %+v", applied)
+	}
+}
+
+func TestOperationOutOfOrder(t *testing.T) {
+	files := map[string]string{
+		"1700000003_create_posts_up.sql":   "-- Description: Create posts\nCREATE TABLE posts (id INT);\n",
+		"1700000003_create_posts_down.sql": "-- Description: Create posts\nDROP TABLE posts;\n",
+	}
+	for filename, contents := range testMigrationFiles {
+		files[filename] = contents
+	}
+
+	for _, allowOutOfOrder := range []bool{false, true} {
+		env := newTestEnvironment(t, files)
+		env.Config.Environment.AllowOutOfOrder = allowOutOfOrder
+
+		// 1700000002 was merged in after 1700000003 had already been applied
+		migrations, err := env.ListAllMigrations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, migration := range []Migration{migrations[0], migrations[2]} {
+			err = env.ApplyMigration(migration, DirectionUp, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		operation, err := env.NewOperation(&migrations[2], &migrations[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = operation.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		applied, err := env.ListAppliedMigrations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		appliedIDs := []string{}
+		for _, appliedMigration := range applied {
+			appliedIDs = append(appliedIDs, appliedMigration.ID)
+		}
+
+		if !allowOutOfOrder {
+			// the operation only looks past the last applied migration, and upgrading refuses to leave the gap behind
+			if operation.Distance != 0 || strings.Join(appliedIDs, ",") != "1700000001,1700000003" {
+				t.Errorf("expected nothing to be applied, got distance %d and %v", operation.Distance, appliedIDs)
+			}
+
+			_, err = env.upgrade(context.Background(), 0)
+			if !errors.Is(err, ErrMigrationOutOfOrder) {
+				t.Errorf("expected the upgrade to be rejected as out of order, got %v", err)
+			}
+			continue
+		}
+
+		if operation.Direction != DirectionUp || operation.Distance != 1 {
+			t.Errorf("expected to go up 1 migration, got %s", operation.DistanceString())
+		}
+		if len(appliedIDs) != 3 {
+			t.Errorf("expected the older migration to be applied too, got %v", appliedIDs)
+		}
+
+		report, err := env.VerifySafeToApply()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.OK() {
+			t.Errorf("expected the database to be safe to apply to, got %v", report.Problems)
+		}
+	}
+}
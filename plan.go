@@ -0,0 +1,229 @@
+package roamer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A PlannedMigration describes a single migration that an Operation would apply, along with the SQL that would be executed.
+type PlannedMigration struct {
+	Migration Migration
+	Direction Direction
+	Stamp     bool
+
+	// PreStatements are the statements issued before the migration file, such as creating and updating the history table.
+	PreStatements []string
+
+	// BeforeEach is the beforeEach callback that would be run before the migration, if there is one.
+	BeforeEach string
+
+	// Contents is the migration file that would be executed. It is empty if the migration would only be stamped.
+	Contents string
+
+	// AfterEach is the afterEach callback that would be run after the migration file, if there is one.
+	AfterEach string
+
+	// PostStatements are the statements issued after the migration file, to mark it as applied or removed.
+	PostStatements []string
+}
+
+// A Plan describes what an Operation would do, without actually doing it.
+type Plan struct {
+	// BeforeAll is the beforeAll callback that would be run before the first migration, if there is one.
+	BeforeAll string
+
+	// Migrations are the migrations that would be applied, in order.
+	Migrations []PlannedMigration
+
+	// AfterAll is the afterAll callback that would be run after the last migration, if there is one.
+	AfterAll string
+
+	// driverType is the driver that the SQL is written for, which affects how it is split into statements.
+	driverType DriverType
+}
+
+// Plan returns the migrations that Run would apply, along with the SQL it would execute, without changing the database.
+//
+// In an offline environment, the database is not consulted at all. Instead, the plan assumes that the database is at the
+// operation's From migration, creates the history table if it does not exist, and records the time the script is run.
+// Since an existing history table might not have the checksum column, which older versions of roamer did not create, an
+// offline plan does not record checksums. VerifyChecksums skips migrations without one.
+func (o *Operation) Plan() (Plan, error) {
+	return o.PlanContext(context.Background())
+}
+
+// PlanContext returns the migrations that RunContext would apply, using the given context to read the database.
+func (o *Operation) PlanContext(ctx context.Context) (Plan, error) {
+	if o.hasRun {
+		return Plan{}, errors.New("roamer: operation has already been run")
+	}
+
+	err := o.e.checkHeads()
+	if err != nil {
+		return Plan{}, err
+	}
+
+	driverType := o.e.LocalConfig.Database.Driver
+
+	createHistoryTable := historyTableSchema(o.e.historyTable, true)
+	addChecksumColumn := ""
+	var appliedAt interface{} = nowExpression(driverType)
+
+	if o.e.db != nil {
+		err := o.checkFrom(ctx)
+		if err != nil {
+			return Plan{}, err
+		}
+
+		err = o.loadApplied(ctx)
+		if err != nil {
+			return Plan{}, err
+		}
+
+		hasHistoryTable, err := o.e.driver.TableExists(ctx, o.e.db, o.e.historyTable)
+		if err != nil {
+			return Plan{}, err
+		}
+
+		createHistoryTable = ""
+		if !hasHistoryTable {
+			createHistoryTable = historyTableSchema(o.e.historyTable, false)
+		} else {
+			hasChecksumColumn, err := o.e.hasChecksumColumn(ctx, o.e.db)
+			if err != nil {
+				return Plan{}, err
+			}
+
+			if !hasChecksumColumn {
+				addChecksumColumn = addChecksumColumnStatement(o.e.historyTable)
+			}
+		}
+		appliedAt = time.Now().Unix()
+	}
+
+	callbacks := operationCallbacks{}
+	if !o.Stamp {
+		var err error
+		callbacks, err = o.e.readCallbacks(o.Direction)
+		if err != nil {
+			return Plan{}, err
+		}
+	}
+
+	plan := Plan{
+		BeforeAll:  callbacks.beforeAll.contents(),
+		Migrations: []PlannedMigration{},
+		AfterAll:   callbacks.afterAll.contents(),
+
+		driverType: driverType,
+	}
+	for i, migration := range o.migrationsToApply() {
+		plannedMigration := PlannedMigration{
+			Migration: migration,
+			Direction: o.Direction,
+			Stamp:     o.Stamp,
+
+			BeforeEach: callbacks.beforeEach.contents(),
+			AfterEach:  callbacks.afterEach.contents(),
+		}
+
+		if i == 0 && createHistoryTable != "" {
+			plannedMigration.PreStatements = append(plannedMigration.PreStatements, createHistoryTable)
+		}
+		if i == 0 && addChecksumColumn != "" {
+			plannedMigration.PreStatements = append(plannedMigration.PreStatements, addChecksumColumn)
+		}
+
+		before, after := historyStatements(o.e.historyTable, migration, o.Direction, appliedAt)
+		beforeSQL, err := before.literal(driverType)
+		if err != nil {
+			return Plan{}, err
+		}
+		afterSQL, err := after.literal(driverType)
+		if err != nil {
+			return Plan{}, err
+		}
+		plannedMigration.PreStatements = append(plannedMigration.PreStatements, beforeSQL)
+		plannedMigration.PostStatements = append(plannedMigration.PostStatements, afterSQL)
+
+		if !o.Stamp {
+			migrationData, err := o.e.readMigrationFile(migration, o.Direction)
+			if err != nil {
+				return Plan{}, err
+			}
+
+			plannedMigration.Contents = string(migrationData)
+		}
+
+		// like ApplyMigration, record the checksum of the up file, even when only stamping, as long as the history table
+		// is known to have somewhere to put it
+		if o.Direction == DirectionUp && o.e.db != nil {
+			upData, err := o.e.readMigrationFile(migration, DirectionUp)
+			if err != nil {
+				return Plan{}, err
+			}
+
+			checksumUpdate, err := checksumStatement(o.e.historyTable, migration, migrationChecksum(upData)).literal(driverType)
+			if err != nil {
+				return Plan{}, err
+			}
+			plannedMigration.PostStatements = append(plannedMigration.PostStatements, checksumUpdate)
+		}
+
+		plan.Migrations = append(plan.Migrations, plannedMigration)
+	}
+
+	return plan, nil
+}
+
+// SQL returns the plan as a single SQL script, which can be reviewed or run manually.
+func (p Plan) SQL() string {
+	script := ""
+	if p.BeforeAll != "" {
+		script += "-- roamer: beforeAll callback\n"
+		script += scriptSection(p.driverType, p.BeforeAll)
+	}
+
+	for _, plannedMigration := range p.Migrations {
+		if script != "" {
+			script += "\n"
+		}
+
+		script += fmt.Sprintf(
+			"-- roamer: %s migration %s - %s\n",
+			plannedMigration.Direction.String(),
+			plannedMigration.Migration.ID,
+			plannedMigration.Migration.Description,
+		)
+
+		script += scriptSection(p.driverType, plannedMigration.BeforeEach)
+		for _, statement := range plannedMigration.PreStatements {
+			script += scriptSection(p.driverType, statement)
+		}
+		script += scriptSection(p.driverType, plannedMigration.Contents)
+		script += scriptSection(p.driverType, plannedMigration.AfterEach)
+		for _, statement := range plannedMigration.PostStatements {
+			script += scriptSection(p.driverType, statement)
+		}
+	}
+
+	if p.AfterAll != "" {
+		script += "\n-- roamer: afterAll callback\n"
+		script += scriptSection(p.driverType, p.AfterAll)
+	}
+
+	return script
+}
+
+// scriptSection returns the given SQL terminated and ending in a newline, ready to be added to a script.
+// Empty SQL results in an empty string.
+func scriptSection(driverType DriverType, sql string) string {
+	if sql == "" {
+		return ""
+	}
+
+	return strings.TrimRight(terminateStatement(sql, driverType), "\r\n") + "\n"
+}
@@ -0,0 +1,136 @@
+package roamer
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testPlanMigrationFiles = map[string]string{
+	"1700000001_create_users_up.sql":   "-- Description: Create users\nCREATE TABLE users (id INT);\n",
+	"1700000001_create_users_down.sql": "-- Description: Create users\nDROP TABLE users;\n",
+	"1700000002_add_index_up.sql":      "-- Description: Add index\nCREATE INDEX users_id ON users(id)\n", // no semicolon, which the script adds
+	"1700000002_add_index_down.sql":    "-- Description: Add index\nDROP INDEX users_id;\n",
+	"beforeAll_up.sql":                 "SET @started = 1;\n",
+}
+
+func TestPlanOfflineWithoutChecksums(t *testing.T) {
+	env, err := newGraphTestEnvironment(t, DefaultConfig, []string{"1", "2"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := env.ListAllMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	operation, err := env.NewOperation(nil, &migrations[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := operation.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the history table might be from a version of roamer without the checksum column, so the script can't write to it
+	script := plan.SQL()
+	if strings.Contains(script, "SET checksum") {
+		t.Errorf("expected the offline script to not record checksums, got\n%s", script)
+	}
+	if strings.Count(script, "INSERT INTO") != 2 {
+		t.Errorf("expected the offline script to record both migrations, got\n%s", script)
+	}
+}
+
+func TestPlanSQL(t *testing.T) {
+	directory := t.TempDir()
+	for filename, contents := range testPlanMigrationFiles {
+		err := os.WriteFile(filepath.Join(directory, filename), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := DefaultConfig
+	config.Environment.MinimumVersion = ""
+	localConfig := LocalConfig{Database: LocalDatabaseConfig{Driver: DriverTypeMySQL}}
+	env, err := NewOfflineEnvironment(config, localConfig, http.Dir(directory))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := env.ListAllMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		from     *Migration
+		to       *Migration
+		expected []string
+	}{
+		{
+			nil,
+			&migrations[1],
+			[]string{
+				"-- roamer: beforeAll callback",
+				"SET @started = 1;",
+				"-- roamer: up migration 1700000001 - Create users",
+				"CREATE TABLE IF NOT EXISTS roamer_history(",
+				"INSERT INTO roamer_history(id, appliedAt, dirty) VALUES('1700000001', UNIX_TIMESTAMP(), 1);",
+				"-- Description: Create users",
+				"CREATE TABLE users (id INT);",
+				"UPDATE roamer_history SET dirty = 0 WHERE id = '1700000001';",
+				"-- roamer: up migration 1700000002 - Add index",
+				"INSERT INTO roamer_history(id, appliedAt, dirty) VALUES('1700000002', UNIX_TIMESTAMP(), 1);",
+				"-- Description: Add index",
+				"CREATE INDEX users_id ON users(id);",
+				"UPDATE roamer_history SET dirty = 0 WHERE id = '1700000002';",
+			},
+		},
+		{
+			&migrations[1],
+			nil,
+			[]string{
+				"-- roamer: down migration 1700000002 - Add index",
+				"CREATE TABLE IF NOT EXISTS roamer_history(",
+				"UPDATE roamer_history SET dirty = 1 WHERE id = '1700000002';",
+				"-- Description: Add index",
+				"DROP INDEX users_id;",
+				"DELETE FROM roamer_history WHERE id = '1700000002';",
+				"-- roamer: down migration 1700000001 - Create users",
+				"UPDATE roamer_history SET dirty = 1 WHERE id = '1700000001';",
+				"-- Description: Create users",
+				"DROP TABLE users;",
+				"DELETE FROM roamer_history WHERE id = '1700000001';",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		operation, err := env.NewOperation(test.from, test.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan, err := operation.Plan()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// leave out the blank lines and the columns of the history table, which aren't what's being tested
+		script := plan.SQL()
+		lines := []string{}
+		for _, line := range strings.Split(script, "\n") {
+			if line != "" && !strings.HasPrefix(line, "\t") {
+				lines = append(lines, line)
+			}
+		}
+
+		if strings.Join(lines, "\n") != strings.Join(test.expected, "\n") {
+			t.Errorf("%s: got\n%s\nexpected\n%s\nin\n%s", operation.DistanceString(), strings.Join(lines, "\n"), strings.Join(test.expected, "\n"), script)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package roamer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A statement is a single SQL query, along with the arguments for its placeholders.
+type statement struct {
+	query string
+	args  []interface{}
+}
+
+// literal returns the statement with its arguments written inline, so that it can be included in a SQL script.
+func (s statement) literal(driverType DriverType) (string, error) {
+	result := ""
+	argIndex := 0
+	for _, character := range s.query {
+		if character == '?' && argIndex < len(s.args) {
+			value, err := literalValue(driverType, s.args[argIndex])
+			if err != nil {
+				return "", err
+			}
+
+			result += value
+			argIndex++
+			continue
+		}
+
+		result += string(character)
+	}
+
+	return result, nil
+}
+
+// A sqlExpression is a statement argument that is written into SQL scripts as-is, rather than as a quoted value.
+type sqlExpression string
+
+// nowExpression returns an expression that evaluates to the current Unix timestamp when the script is run.
+func nowExpression(driverType DriverType) sqlExpression {
+	if driverType == DriverTypeSQLite3 {
+		return "CAST(strftime('%s', 'now') AS INTEGER)"
+	}
+
+	return "UNIX_TIMESTAMP()"
+}
+
+// literalValue returns the given statement argument written as SQL for the given driver.
+func literalValue(driverType DriverType, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case sqlExpression:
+		return string(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case string:
+		escaped := strings.Replace(v, "'", "''", -1)
+		if driverType == DriverTypeMySQL {
+			escaped = strings.Replace(escaped, "\\", "\\\\", -1)
+		}
+		return "'" + escaped + "'", nil
+	}
+
+	return "", fmt.Errorf("roamer: cannot write literal of unsupported type %T", value)
+}
+
+// terminateStatement makes sure that the given SQL ends with a semicolon, so that it can be safely followed by another statement.
+// The semicolon goes right after the last token that isn't a comment, so that it isn't swallowed by a trailing comment.
+// SQL consisting only of comments and whitespace is returned unchanged.
+func terminateStatement(sql string, driverType DriverType) string {
+	tokens := tokenizeSQL(sql, driverType)
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if tokens[i].kind == tokenComment {
+			continue
+		}
+
+		if tokens[i].kind == tokenSymbol && tokens[i].text == ";" {
+			return sql
+		}
+
+		end := tokens[i].offset + len(tokens[i].text)
+		return sql[:end] + ";" + sql[end:]
+	}
+
+	return sql
+}
@@ -0,0 +1,80 @@
+package roamer
+
+import (
+	"testing"
+)
+
+func TestTerminateStatement(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{"SELECT 1", "SELECT 1;"},
+		{"SELECT 1;", "SELECT 1;"},
+		{"SELECT 1\n", "SELECT 1;\n"},
+		{"SELECT 1 -- note\n", "SELECT 1; -- note\n"},
+		{"SELECT 1 /* note */", "SELECT 1; /* note */"},
+		{"SELECT 1; -- note\n", "SELECT 1; -- note\n"},
+		{"SELECT 1\n-- note\n\n", "SELECT 1;\n-- note\n\n"},
+		{"SELECT '-- not a comment'", "SELECT '-- not a comment';"},
+		{"SELECT ';'", "SELECT ';';"},
+		{"-- only a comment\n", "-- only a comment\n"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		result := terminateStatement(test.sql, DriverTypeSQLite3)
+		if result != test.expected {
+			t.Errorf("terminateStatement(%q) = %q, expected %q", test.sql, result, test.expected)
+		}
+	}
+}
+
+func TestLiteralValue(t *testing.T) {
+	tests := []struct {
+		driverType DriverType
+		value      interface{}
+		expected   string
+	}{
+		{DriverTypeSQLite3, 42, "42"},
+		{DriverTypeSQLite3, int64(1700000000), "1700000000"},
+		{DriverTypeSQLite3, "it's", "'it''s'"},
+		{DriverTypeSQLite3, `a\b`, `'a\b'`},
+		{DriverTypeMySQL, `a\b`, `'a\\b'`},
+		{DriverTypeMySQL, sqlExpression("UNIX_TIMESTAMP()"), "UNIX_TIMESTAMP()"},
+	}
+
+	for _, test := range tests {
+		result, err := literalValue(test.driverType, test.value)
+		if err != nil {
+			t.Errorf("literalValue(%s, %#v) failed: %s", test.driverType, test.value, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("literalValue(%s, %#v) = %s, expected %s", test.driverType, test.value, result, test.expected)
+		}
+	}
+
+	_, err := literalValue(DriverTypeSQLite3, 1.5)
+	if err == nil {
+		t.Error("expected an error for an unsupported type")
+	}
+}
+
+func TestStatementLiteral(t *testing.T) {
+	s := statement{"UPDATE history SET checksum = ? WHERE id = ?", []interface{}{"abc", "1700000001"}}
+	result, err := s.literal(DriverTypeSQLite3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "UPDATE history SET checksum = 'abc' WHERE id = '1700000001'"
+	if result != expected {
+		t.Errorf("got %q, expected %q", result, expected)
+	}
+
+	_, err = statement{"SELECT ?", []interface{}{[]byte("x")}}.literal(DriverTypeSQLite3)
+	if err == nil {
+		t.Error("expected an error for an unsupported argument")
+	}
+}
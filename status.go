@@ -0,0 +1,170 @@
+package roamer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// A StatusMigration describes a single migration in a StatusReport.
+type StatusMigration struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	AppliedAt   int    `json:"appliedAt,omitempty"`
+}
+
+// StatusChecks contains the results of the verification checks in a StatusReport.
+type StatusChecks struct {
+	NoDirty bool `json:"noDirty"`
+	Exist   bool `json:"exist"`
+	Order   bool `json:"order"`
+}
+
+// A StatusProblem describes one of the problems found by the verification checks in a StatusReport.
+type StatusProblem struct {
+	// Kind is the KindName of the VerificationProblem.
+	Kind      string `json:"kind"`
+	ID        string `json:"id"`
+	RelatedID string `json:"relatedId,omitempty"`
+	Message   string `json:"message"`
+}
+
+// A StatusReport describes the state of an environment's database, compared to the migrations on disk.
+type StatusReport struct {
+	// UpToDate is true if every migration on disk has been applied, and all of the checks passed.
+	UpToDate bool `json:"upToDate"`
+
+	LastApplied *StatusMigration  `json:"lastApplied"`
+	Pending     []StatusMigration `json:"pending"`
+	Dirty       []StatusMigration `json:"dirty"`
+
+	Checks   StatusChecks    `json:"checks"`
+	Problems []StatusProblem `json:"problems"`
+
+	// Error is set if the status could not be read, in which case the rest of the report is empty.
+	Error string `json:"error,omitempty"`
+}
+
+// Status returns a StatusReport describing the environment's database.
+// The history table is only read once, so everything in the report comes from the same snapshot of it.
+func (e *Environment) Status(ctx context.Context) (StatusReport, error) {
+	report := StatusReport{
+		Pending:  []StatusMigration{},
+		Dirty:    []StatusMigration{},
+		Problems: []StatusProblem{},
+	}
+
+	allMigrations, err := e.ListAllMigrations()
+	if err != nil {
+		return StatusReport{}, err
+	}
+
+	appliedMigrations, err := e.ListAppliedMigrationsContext(ctx)
+	if err != nil {
+		return StatusReport{}, err
+	}
+
+	applied := map[string]bool{}
+	var lastApplied *AppliedMigration
+	for i, appliedMigration := range appliedMigrations {
+		applied[appliedMigration.ID] = true
+
+		// this matches GetLastAppliedMigration, which orders by appliedAt and then by ID
+		if lastApplied == nil || appliedMigration.AppliedAt > lastApplied.AppliedAt || (appliedMigration.AppliedAt == lastApplied.AppliedAt && appliedMigration.ID > lastApplied.ID) {
+			lastApplied = &appliedMigrations[i]
+		}
+
+		if appliedMigration.Dirty {
+			report.Dirty = append(report.Dirty, *e.statusMigration(appliedMigration.ID, appliedMigration.AppliedAt))
+		}
+	}
+
+	if lastApplied != nil {
+		report.LastApplied = e.statusMigration(lastApplied.ID, lastApplied.AppliedAt)
+	}
+
+	for _, migration := range allMigrations {
+		if !applied[migration.ID] {
+			report.Pending = append(report.Pending, *e.statusMigration(migration.ID, 0))
+		}
+	}
+
+	noDirtyReport := e.verifyNoDirty(appliedMigrations)
+	existReport := e.verifyExist(appliedMigrations)
+	orderReport := e.verifyOrder(appliedMigrations)
+
+	report.Checks.NoDirty = noDirtyReport.OK()
+	report.Checks.Exist = existReport.OK()
+	report.Checks.Order = orderReport.OK()
+
+	verificationReport := VerificationReport{}
+	for _, checkReport := range []VerificationReport{noDirtyReport, existReport, orderReport} {
+		for _, problem := range checkReport.Problems {
+			verificationReport.add(problem)
+		}
+	}
+	for _, problem := range verificationReport.Problems {
+		report.Problems = append(report.Problems, StatusProblem{
+			Kind:      problem.KindName(),
+			ID:        problem.ID,
+			RelatedID: problem.RelatedID,
+			Message:   problem.Error(),
+		})
+	}
+
+	report.UpToDate = len(report.Pending) == 0 && report.Checks.NoDirty && report.Checks.Exist && report.Checks.Order
+
+	return report, nil
+}
+
+// statusMigration returns a StatusMigration for the migration with the given ID, which may not exist on disk.
+func (e *Environment) statusMigration(id string, appliedAt int) *StatusMigration {
+	result := StatusMigration{
+		ID:        id,
+		AppliedAt: appliedAt,
+	}
+
+	migration, err := e.GetMigrationByID(id)
+	if err == nil {
+		result.Description = migration.Description
+	}
+
+	return &result
+}
+
+// StatusHandler returns an http.Handler that responds with the environment's StatusReport, encoded as JSON.
+//
+// By default, it is meant to be used as a readiness check. It responds with 200 OK if the database is up-to-date, and
+// with 503 Service Unavailable if there are pending or dirty migrations, a check failed, or the status could not be read.
+//
+// If the request has the query parameter "probe=live", it is meant to be used as a liveness check instead. It then
+// responds with 200 OK regardless of the database's state, since restarting won't change it, but still includes the report.
+//
+// The handler is usually reachable without authentication, so if the status can't be read, the error is logged and the
+// report only says that the status is unavailable.
+func StatusHandler(e *Environment) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		liveness := r.URL.Query().Get("probe") == "live"
+
+		report, err := e.Status(r.Context())
+		if err != nil {
+			e.logger.Warn("roamer: could not get status", "error", err)
+			report = StatusReport{
+				Pending:  []StatusMigration{},
+				Dirty:    []StatusMigration{},
+				Problems: []StatusProblem{},
+				Error:    "status unavailable",
+			}
+		}
+
+		statusCode := http.StatusOK
+		if !report.UpToDate && !liveness {
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(report)
+	})
+}
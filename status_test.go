@@ -0,0 +1,149 @@
+//go:build !nocgo
+// +build !nocgo
+
+package roamer
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// getStatus requests the status from the environment's StatusHandler, returning the status code and the decoded report.
+func getStatus(t *testing.T, env *Environment, query string) (int, StatusReport) {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	StatusHandler(env).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status"+query, nil))
+
+	if recorder.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected Content-Type %s", recorder.Header().Get("Content-Type"))
+	}
+
+	report := StatusReport{}
+	err := json.Unmarshal(recorder.Body.Bytes(), &report)
+	if err != nil {
+		t.Fatalf("could not decode the response: %s", err)
+	}
+
+	return recorder.Code, report
+}
+
+func TestStatusHandlerPending(t *testing.T) {
+	env := newTestEnvironment(t, testMigrationFiles)
+
+	code, report := getStatus(t, env, "")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", code)
+	}
+	if report.UpToDate {
+		t.Error("expected the report to not be up-to-date")
+	}
+	if len(report.Pending) != 2 || report.Pending[0].ID != "1700000001" || report.Pending[0].Description != "Create users" {
+		t.Errorf("unexpected pending migrations %v", report.Pending)
+	}
+	if report.LastApplied != nil {
+		t.Errorf("expected no last applied migration, got %v", report.LastApplied)
+	}
+
+	code, _ = getStatus(t, env, "?probe=live")
+	if code != http.StatusOK {
+		t.Errorf("expected status 200 for the liveness probe, got %d", code)
+	}
+}
+
+func TestStatusHandlerUpToDate(t *testing.T) {
+	env := newTestEnvironment(t, testMigrationFiles)
+
+	migrations, err := env.ListAllMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	operation, err := env.NewOperation(nil, &migrations[len(migrations)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = operation.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, report := getStatus(t, env, "")
+	if code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", code)
+	}
+	if !report.UpToDate || len(report.Pending) != 0 || len(report.Problems) != 0 {
+		t.Errorf("expected the report to be up-to-date, got %+v", report)
+	}
+	if report.LastApplied == nil || report.LastApplied.ID != "1700000002" {
+		t.Errorf("expected the last applied migration to be 1700000002, got %v", report.LastApplied)
+	}
+}
+
+func TestStatusHandlerProblems(t *testing.T) {
+	env := newTestEnvironment(t, testMigrationFiles)
+
+	migrations, err := env.ListAllMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.ApplyMigration(migrations[1], DirectionUp, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = env.db.Exec("INSERT INTO " + env.historyTable + " (id, appliedAt, dirty) VALUES ('1700000003', 1, 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, report := getStatus(t, env, "")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", code)
+	}
+
+	if report.Checks.NoDirty || report.Checks.Exist || report.Checks.Order {
+		t.Errorf("expected every check to fail, got %+v", report.Checks)
+	}
+	if len(report.Dirty) != 1 || report.Dirty[0].ID != "1700000003" {
+		t.Errorf("unexpected dirty migrations %v", report.Dirty)
+	}
+
+	// the missing migration is found by two checks, but should only be listed once
+	kinds := []string{}
+	for _, problem := range report.Problems {
+		kinds = append(kinds, problem.Kind+" "+problem.ID)
+	}
+	expected := "dirty 1700000003, missing 1700000003, outOfOrder 1700000002"
+	if strings.Join(kinds, ", ") != expected {
+		t.Errorf("got problems %s, expected %s", strings.Join(kinds, ", "), expected)
+	}
+}
+
+func TestStatusHandlerError(t *testing.T) {
+	env := newTestEnvironment(t, testMigrationFiles)
+
+	var logs bytes.Buffer
+	env.logger = slog.New(slog.NewTextHandler(&logs, nil))
+	env.db.Close()
+
+	code, report := getStatus(t, env, "")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", code)
+	}
+	if report.Error != "status unavailable" {
+		t.Errorf("expected a generic error, got %q", report.Error)
+	}
+	if !strings.Contains(logs.String(), "database is closed") {
+		t.Errorf("expected the error to be logged, got %q", logs.String())
+	}
+
+	// a database that can't be read isn't fixed by restarting either
+	code, _ = getStatus(t, env, "?probe=live")
+	if code != http.StatusOK {
+		t.Errorf("expected status 200 for the liveness probe, got %d", code)
+	}
+}
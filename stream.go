@@ -0,0 +1,93 @@
+package roamer
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+)
+
+var reHistoryTableName = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+// WithStream is an EnvironmentOption that makes the Environment use the stream of migrations with the given name,
+// as defined in the Streams section of the config. The stream has its own migrations directory and history table.
+//
+// When using NewEnvironment or NewOfflineEnvironment, the http.FileSystem given should be the stream's migrations directory.
+func WithStream(name string) EnvironmentOption {
+	return func(e *Environment) {
+		e.stream = name
+	}
+}
+
+// onDisk is an EnvironmentOption that makes the Environment read its migrations from the directory given in its config,
+// relative to the given path.
+func onDisk(basePath string) EnvironmentOption {
+	return func(e *Environment) {
+		e.basePath = basePath
+	}
+}
+
+// withOnDisk returns the given options followed by onDisk(basePath). The options are copied first, since appending to
+// them directly could write into spare room in the caller's slice, which another call might be appending to as well.
+func withOnDisk(options []EnvironmentOption, basePath string) []EnvironmentOption {
+	result := make([]EnvironmentOption, 0, len(options)+1)
+	result = append(result, options...)
+
+	return append(result, onDisk(basePath))
+}
+
+// withoutLocalConfig is an EnvironmentOption that marks the Environment as having been read from disk without a local
+// config file, so that the driver in its local config is only the default one.
+func withoutLocalConfig() EnvironmentOption {
+	return func(e *Environment) {
+		e.localConfigMissing = true
+	}
+}
+
+// Stream returns the name of the stream of migrations used by the environment, or an empty string if it uses the
+// migrations in Environment.MigrationDirectory.
+func (e *Environment) Stream() string {
+	return e.stream
+}
+
+// MigrationDirectoryPath returns the path of the directory that the environment reads its migrations from, or an empty
+// string if the environment was not loaded from disk.
+func (e *Environment) MigrationDirectoryPath() string {
+	return e.pathOnDisk
+}
+
+// setUpStream finds the history table and, if the environment is on disk, the migrations directory of the environment's stream.
+func (e *Environment) setUpStream() error {
+	e.historyTable = tableNameRoamerHistory
+	migrationDirectory := e.Config.Environment.MigrationDirectory
+	if e.database != "" {
+		migrationDirectory = e.Config.Databases[e.database].MigrationDirectory
+	}
+
+	if e.stream != "" {
+		streamConfig, exists := e.Config.Streams[e.stream]
+		if !exists {
+			return fmt.Errorf("roamer: there is no stream named '%s'", e.stream)
+		}
+
+		e.historyTable = streamConfig.HistoryTable
+		if e.historyTable == "" {
+			e.historyTable = tableNameRoamerHistory + "_" + e.stream
+		}
+		if !reHistoryTableName.MatchString(e.historyTable) {
+			return fmt.Errorf("roamer: stream '%s' has invalid history table name '%s'", e.stream, e.historyTable)
+		}
+
+		migrationDirectory = streamConfig.MigrationDirectory
+		if migrationDirectory == "" {
+			return fmt.Errorf("roamer: stream '%s' is missing a MigrationDirectory", e.stream)
+		}
+	}
+
+	if e.basePath != "" {
+		e.pathOnDisk = path.Join(e.basePath, migrationDirectory)
+		e.fs = http.Dir(e.pathOnDisk)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package roamer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestEnvironment writes an environment to a new directory, with the given config files and a migration in each
+// of the given migrations directories, and returns the path of the directory.
+func writeTestEnvironment(t *testing.T, config string, localConfig string, migrationDirectories map[string]string) string {
+	t.Helper()
+
+	basePath := t.TempDir()
+	err := os.WriteFile(filepath.Join(basePath, "roamer.toml"), []byte(config), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(basePath, "roamer.local.toml"), []byte(localConfig), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for directory, id := range migrationDirectories {
+		err := os.MkdirAll(filepath.Join(basePath, directory), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, direction := range []string{"up", "down"} {
+			filename := filepath.Join(basePath, directory, id+"_migration_"+direction+".sql")
+			err := os.WriteFile(filename, []byte("-- Description: Migration "+id+"\nSELECT 1;\n"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return basePath
+}
+
+const testStreamsConfig = `
+[Environment]
+MigrationDirectory = "migrations/"
+
+[Streams.reports]
+MigrationDirectory = "reports/"
+
+[Streams.audit]
+MigrationDirectory = "audit/"
+HistoryTable = "audit_history"
+`
+
+func TestWithStream(t *testing.T) {
+	basePath := writeTestEnvironment(t, testStreamsConfig, "[Database]\nDriver = \"mysql\"\n", map[string]string{
+		"migrations": "1",
+		"reports":    "2",
+		"audit":      "3",
+	})
+
+	tests := []struct {
+		stream       string
+		historyTable string
+		directory    string
+		migrationID  string
+	}{
+		{"", "roamer_history", "migrations", "1"},
+		{"reports", "roamer_history_reports", "reports", "2"},
+		{"audit", "audit_history", "audit", "3"},
+	}
+
+	for _, test := range tests {
+		options := []EnvironmentOption{}
+		if test.stream != "" {
+			options = append(options, WithStream(test.stream))
+		}
+
+		env, err := NewOfflineEnvironmentFromDisk(basePath, "local", options...)
+		if err != nil {
+			t.Fatalf("stream '%s': %s", test.stream, err)
+		}
+
+		if env.Stream() != test.stream || env.historyTable != test.historyTable {
+			t.Errorf("stream '%s': got stream '%s' with history table %s, expected %s", test.stream, env.Stream(), env.historyTable, test.historyTable)
+		}
+		if env.MigrationDirectoryPath() != filepath.Join(basePath, test.directory) {
+			t.Errorf("stream '%s': got migrations directory %s", test.stream, env.MigrationDirectoryPath())
+		}
+
+		migrations, err := env.ListAllMigrations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if migrationIDs(migrations) != test.migrationID {
+			t.Errorf("stream '%s': got migrations %s, expected %s", test.stream, migrationIDs(migrations), test.migrationID)
+		}
+	}
+
+	_, err := NewOfflineEnvironmentFromDisk(basePath, "local", WithStream("missing"))
+	if err == nil {
+		t.Error("expected an error for a stream that doesn't exist")
+	}
+}
+
+func TestWithOnDiskCopiesOptions(t *testing.T) {
+	basePath := writeTestEnvironment(t, testStreamsConfig, "[Database]\nDriver = \"mysql\"\n", map[string]string{
+		"reports": "2",
+		"audit":   "3",
+	})
+
+	// the options have spare room, which the caller might be about to append something else to
+	options := make([]EnvironmentOption, 1, 4)
+	options[0] = WithStream("reports")
+
+	for _, check := range []bool{false, true} {
+		if check {
+			_, errs := CheckMigrationFiles(basePath, "local", options...)
+			if len(errs) != 0 {
+				t.Fatal(errs)
+			}
+		} else {
+			_, err := NewOfflineEnvironmentFromDisk(basePath, "local", options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for i, option := range options[:cap(options)][1:] {
+			if option != nil {
+				t.Errorf("expected the spare room in the options to be left alone, but %d was set", i+1)
+			}
+		}
+	}
+}
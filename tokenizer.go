@@ -0,0 +1,201 @@
+package roamer
+
+import (
+	"strings"
+)
+
+// A tokenKind is the kind of a token of SQL.
+type tokenKind int
+
+const (
+	// tokenWord is a keyword, an unquoted identifier, or a number.
+	tokenWord tokenKind = iota
+
+	// tokenQuotedIdentifier is an identifier in backticks, double quotes, or square brackets.
+	tokenQuotedIdentifier
+
+	// tokenString is a string in single quotes.
+	tokenString
+
+	// tokenComment is a comment, either starting with "--" or "#" and running to the end of the line, or between "/*" and "*/".
+	tokenComment
+
+	// tokenSymbol is any other single character, such as an operator, a comma, or a semicolon.
+	tokenSymbol
+)
+
+// A token is a piece of SQL, found by tokenizeSQL.
+type token struct {
+	kind tokenKind
+	text string
+
+	// line is the line of the SQL that the token starts on, starting from 1.
+	line int
+
+	// offset is the position in the SQL that the token starts at, in bytes.
+	offset int
+}
+
+// isWord returns true if the token is the given keyword, in any case.
+func (t token) isWord(keyword string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, keyword)
+}
+
+// isWordChar returns true if the given character can be part of a keyword, an unquoted identifier, or a number.
+func isWordChar(c byte) bool {
+	return c == '_' || c == '$' || (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || c >= 0x80
+}
+
+// tokenizeSQL splits the given SQL into tokens, skipping whitespace. It does not check that the SQL is valid, and an
+// unterminated string or comment just runs to the end of the SQL.
+// MySQL allows backslash escapes in strings, so they are only recognized for DriverTypeMySQL.
+func tokenizeSQL(sql string, driverType DriverType) []token {
+	tokens := []token{}
+	line := 1
+	i := 0
+	for i < len(sql) {
+		c := sql[i]
+		start := i
+
+		switch {
+		case c == '\n':
+			line++
+			i++
+			continue
+
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+
+		case c == '#' || (c == '-' && strings.HasPrefix(sql[i:], "--")):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			tokens = append(tokens, token{tokenComment, strings.TrimRight(sql[start:i], "\r"), line, start})
+			continue
+
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end == -1 {
+				i = len(sql)
+			} else {
+				i += 2 + end + 2
+			}
+
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+
+			i++
+			for i < len(sql) {
+				if sql[i] == '\\' && c == '\'' && driverType == DriverTypeMySQL {
+					i += 2
+					continue
+				}
+				if sql[i] == closing {
+					if i+1 < len(sql) && sql[i+1] == closing && c != '[' {
+						// a doubled quote is an escaped quote
+						i += 2
+						continue
+					}
+
+					i++
+					break
+				}
+
+				i++
+			}
+			if i > len(sql) {
+				i = len(sql)
+			}
+
+		case isWordChar(c):
+			for i < len(sql) && isWordChar(sql[i]) {
+				i++
+			}
+
+		default:
+			i++
+		}
+
+		text := sql[start:i]
+		kind := tokenSymbol
+		switch {
+		case strings.HasPrefix(text, "/*"):
+			kind = tokenComment
+		case c == '\'':
+			kind = tokenString
+		case c == '"' || c == '`' || c == '[':
+			kind = tokenQuotedIdentifier
+		case isWordChar(c):
+			kind = tokenWord
+		}
+
+		tokens = append(tokens, token{kind, text, line, start})
+		line += strings.Count(text, "\n")
+	}
+
+	return tokens
+}
+
+// A sqlStatement is a single statement of SQL, split out of a file by splitStatements.
+type sqlStatement struct {
+	// tokens are the tokens of the statement, without its comments or the semicolon that ends it.
+	tokens []token
+
+	// comments are the comments before the statement, since the end of the previous one, and inside it.
+	comments []token
+
+	// line is the line that the statement starts on.
+	line int
+}
+
+// startsWith returns true if the statement starts with the given keywords, in any case.
+func (s sqlStatement) startsWith(keywords ...string) bool {
+	if len(s.tokens) < len(keywords) {
+		return false
+	}
+
+	for i, keyword := range keywords {
+		if !s.tokens[i].isWord(keyword) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitStatements groups the given tokens into statements, which are separated by semicolons.
+// Comments after the last statement are not part of any statement.
+func splitStatements(tokens []token) []sqlStatement {
+	statements := []sqlStatement{}
+	current := sqlStatement{}
+	for _, t := range tokens {
+		if t.kind == tokenComment {
+			current.comments = append(current.comments, t)
+			continue
+		}
+
+		if t.kind == tokenSymbol && t.text == ";" {
+			// an empty statement keeps its comments, so that they belong to the next one
+			if len(current.tokens) > 0 {
+				statements = append(statements, current)
+				current = sqlStatement{}
+			}
+			continue
+		}
+
+		if len(current.tokens) == 0 {
+			current.line = t.line
+		}
+		current.tokens = append(current.tokens, t)
+	}
+
+	if len(current.tokens) > 0 {
+		statements = append(statements, current)
+	}
+
+	return statements
+}
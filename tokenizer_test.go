@@ -0,0 +1,100 @@
+package roamer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeSQL(t *testing.T) {
+	sql := "SELECT `a b`, 'it''s' -- note\nFROM t /* multi\nline */ WHERE x >= 1;\n# hash comment\n"
+	tokens := tokenizeSQL(sql, DriverTypeMySQL)
+
+	expected := []token{
+		{tokenWord, "SELECT", 1, 0},
+		{tokenQuotedIdentifier, "`a b`", 1, 7},
+		{tokenSymbol, ",", 1, 12},
+		{tokenString, "'it''s'", 1, 14},
+		{tokenComment, "-- note", 1, 22},
+		{tokenWord, "FROM", 2, 30},
+		{tokenWord, "t", 2, 35},
+		{tokenComment, "/* multi\nline */", 2, 37},
+		{tokenWord, "WHERE", 3, 54},
+		{tokenWord, "x", 3, 60},
+		{tokenSymbol, ">", 3, 62},
+		{tokenSymbol, "=", 3, 63},
+		{tokenWord, "1", 3, 65},
+		{tokenSymbol, ";", 3, 66},
+		{tokenComment, "# hash comment", 4, 68},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("got\n%+v\nexpected\n%+v", tokens, expected)
+	}
+
+	for _, token := range tokens {
+		if sql[token.offset:token.offset+len(token.text)] != token.text {
+			t.Errorf("token %q is not at its offset %d", token.text, token.offset)
+		}
+	}
+}
+
+func TestTokenizeSQLBackslashes(t *testing.T) {
+	sql := `SELECT 'a\'; DROP TABLE t; --'`
+
+	// MySQL treats the backslash as an escape, so everything after it is still in the string
+	tokens := tokenizeSQL(sql, DriverTypeMySQL)
+	if len(tokens) != 2 || tokens[1].kind != tokenString {
+		t.Errorf("expected a single string after SELECT, got %+v", tokens)
+	}
+
+	// SQLite doesn't, so the string ends at the second quote
+	tokens = tokenizeSQL(sql, DriverTypeSQLite3)
+	if len(tokens) < 3 || tokens[1].text != `'a\'` || tokens[2].text != ";" {
+		t.Errorf("expected the string to end before the semicolon, got %+v", tokens)
+	}
+}
+
+func TestTokenizeSQLUnterminated(t *testing.T) {
+	for _, sql := range []string{"SELECT 'unterminated", "SELECT /* unterminated", "SELECT \"unterminated"} {
+		tokens := tokenizeSQL(sql, DriverTypeSQLite3)
+		if len(tokens) != 2 || tokens[1].text != sql[len("SELECT "):] {
+			t.Errorf("expected %q to run to the end, got %+v", sql, tokens)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	sql := "-- first\nCREATE TABLE a (id INT);\n;\n-- second\nDROP TABLE b /* inside */;\nSELECT 1\n-- trailing\n"
+	statements := splitStatements(tokenizeSQL(sql, DriverTypeSQLite3))
+
+	if len(statements) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(statements))
+	}
+
+	tests := []struct {
+		line     int
+		tokens   int
+		comments []string
+	}{
+		{2, 7, []string{"-- first"}},
+		{5, 3, []string{"-- second", "/* inside */"}},
+		{6, 2, []string{"-- trailing"}},
+	}
+	for i, test := range tests {
+		statement := statements[i]
+		if statement.line != test.line || len(statement.tokens) != test.tokens {
+			t.Errorf("statement %d: got line %d with %d tokens, expected line %d with %d tokens", i, statement.line, len(statement.tokens), test.line, test.tokens)
+		}
+
+		comments := []string{}
+		for _, comment := range statement.comments {
+			comments = append(comments, comment.text)
+		}
+		if !reflect.DeepEqual(comments, test.comments) {
+			t.Errorf("statement %d: got comments %v, expected %v", i, comments, test.comments)
+		}
+	}
+
+	if !statements[1].startsWith("drop", "table") || statements[1].startsWith("DROP", "TABLE", "b", "c") {
+		t.Error("startsWith did not match the statement's keywords")
+	}
+}
@@ -1,92 +1,310 @@
 package roamer
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotSafeToApply is matched by the VerificationError that is returned when migrations cannot be applied to a
+// database, because VerifySafeToApply found problems.
+var ErrNotSafeToApply = errors.New("roamer: it is not safe to apply migrations to the database")
+
+// ErrMigrationDirty is the kind of VerificationProblem for an applied migration that is marked as dirty.
+var ErrMigrationDirty = errors.New("roamer: migration is marked as dirty")
+
+// ErrMigrationMissing is the kind of VerificationProblem for an applied migration that does not exist on disk.
+var ErrMigrationMissing = errors.New("roamer: applied migration does not exist on disk")
+
+// ErrMigrationOutOfOrder is the kind of VerificationProblem for a migration that was applied before one that comes earlier on disk.
+var ErrMigrationOutOfOrder = errors.New("roamer: migration was applied out of order")
+
+// ErrMigrationParentNotApplied is the kind of VerificationProblem for a migration in a revision graph that was applied
+// without one of its parents.
+var ErrMigrationParentNotApplied = errors.New("roamer: migration was applied without its parent")
+
+// ErrMigrationChanged is the kind of VerificationProblem for an applied migration whose up file has changed since it was applied.
+var ErrMigrationChanged = errors.New("roamer: migration has changed since it was applied")
+
+// A VerificationProblem describes a problem with a single migration, found by one of the verification checks.
+// It can be matched against its kind with errors.Is.
+type VerificationProblem struct {
+	// Kind is one of ErrMigrationDirty, ErrMigrationMissing, ErrMigrationOutOfOrder, ErrMigrationParentNotApplied, or
+	// ErrMigrationChanged.
+	Kind error
+
+	// ID is the ID of the migration with the problem.
+	ID string
+
+	// RelatedID is the ID of the earlier migration that has not been applied, for ErrMigrationOutOfOrder, or of the
+	// parent that has not been applied, for ErrMigrationParentNotApplied. Otherwise, it is empty.
+	RelatedID string
+}
+
+// Error returns a string representation of the VerificationProblem.
+func (p VerificationProblem) Error() string {
+	switch p.Kind {
+	case ErrMigrationDirty:
+		return fmt.Sprintf("roamer: migration %s is marked as dirty", p.ID)
+	case ErrMigrationMissing:
+		return fmt.Sprintf("roamer: applied migration %s does not exist on disk", p.ID)
+	case ErrMigrationOutOfOrder:
+		return fmt.Sprintf("roamer: migration %s was applied before %s, which comes earlier on disk", p.ID, p.RelatedID)
+	case ErrMigrationParentNotApplied:
+		return fmt.Sprintf("roamer: migration %s was applied without its parent %s", p.ID, p.RelatedID)
+	case ErrMigrationChanged:
+		return fmt.Sprintf("roamer: migration %s has changed since it was applied", p.ID)
+	}
+
+	return fmt.Sprintf("roamer: migration %s: %s", p.ID, p.Kind.Error())
+}
+
+// KindName returns a short name for the kind of the VerificationProblem, for use in machine-readable output.
+// It is one of "dirty", "missing", "outOfOrder", "parentNotApplied", or "changed".
+func (p VerificationProblem) KindName() string {
+	switch p.Kind {
+	case ErrMigrationDirty:
+		return "dirty"
+	case ErrMigrationMissing:
+		return "missing"
+	case ErrMigrationOutOfOrder:
+		return "outOfOrder"
+	case ErrMigrationParentNotApplied:
+		return "parentNotApplied"
+	case ErrMigrationChanged:
+		return "changed"
+	}
+
+	return "unknown"
+}
+
+// Unwrap returns the kind of the VerificationProblem.
+func (p VerificationProblem) Unwrap() error {
+	return p.Kind
+}
+
+// A VerificationReport lists the problems found by one or more of the verification checks.
+type VerificationReport struct {
+	Problems []VerificationProblem
+}
+
+// OK returns true if the checks found no problems.
+func (r VerificationReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// IDs returns the IDs of the migrations with problems of the given kind, in the order that they were found.
+func (r VerificationReport) IDs(kind error) []string {
+	ids := []string{}
+	for _, problem := range r.Problems {
+		if problem.Kind == kind {
+			ids = append(ids, problem.ID)
+		}
+	}
+
+	return ids
+}
+
+// Err returns nil if the checks found no problems, and otherwise a VerificationError with all of them.
+func (r VerificationReport) Err() error {
+	if r.OK() {
+		return nil
+	}
+
+	return VerificationError{r.Problems}
+}
+
+// add adds the given problem to the report, unless an identical problem has already been found by another check.
+func (r *VerificationReport) add(problem VerificationProblem) {
+	for _, existingProblem := range r.Problems {
+		if existingProblem == problem {
+			return
+		}
+	}
+
+	r.Problems = append(r.Problems, problem)
+}
+
+// VerificationError is reported when it is not safe to apply migrations, because verification found problems.
+// It matches ErrNotSafeToApply, and the kind of each of its problems, with errors.Is.
+type VerificationError struct {
+	Problems []VerificationProblem
+}
+
+// Error returns a string representation of the VerificationError.
+func (e VerificationError) Error() string {
+	messages := []string{}
+	for _, problem := range e.Problems {
+		messages = append(messages, strings.TrimPrefix(problem.Error(), "roamer: "))
+	}
+
+	return "roamer: it is not safe to apply migrations: " + strings.Join(messages, "; ")
+}
+
+// Is returns true if the target is ErrNotSafeToApply.
+func (e VerificationError) Is(target error) bool {
+	return target == ErrNotSafeToApply
+}
+
+// Unwrap returns the problems of the VerificationError.
+func (e VerificationError) Unwrap() []error {
+	errs := []error{}
+	for _, problem := range e.Problems {
+		errs = append(errs, problem)
+	}
+
+	return errs
+}
+
 // VerifyNoDirty checks that the environment has no dirty migrations.
-func (e *Environment) VerifyNoDirty() (bool, error) {
-	appliedMigrations, err := e.ListAppliedMigrations()
+func (e *Environment) VerifyNoDirty() (VerificationReport, error) {
+	return e.VerifyNoDirtyContext(context.Background())
+}
+
+// VerifyNoDirtyContext checks that the environment has no dirty migrations, using the given context.
+func (e *Environment) VerifyNoDirtyContext(ctx context.Context) (VerificationReport, error) {
+	appliedMigrations, err := e.ListAppliedMigrationsContext(ctx)
 	if err != nil {
-		return false, err
+		return VerificationReport{}, err
 	}
 
+	return e.verifyNoDirty(appliedMigrations), nil
+}
+
+// verifyNoDirty checks that none of the given applied migrations are dirty.
+func (e *Environment) verifyNoDirty(appliedMigrations []AppliedMigration) VerificationReport {
+	report := VerificationReport{}
 	for _, appliedMigration := range appliedMigrations {
 		if appliedMigration.Dirty {
-			return false, nil
+			e.logger.Warn("roamer: verification failed, migration is dirty", "id", appliedMigration.ID)
+			report.add(VerificationProblem{Kind: ErrMigrationDirty, ID: appliedMigration.ID})
 		}
 	}
 
-	return true, nil
+	return report
 }
 
 // VerifyExist checks that that all applied migrations exist on disk.
-func (e *Environment) VerifyExist() (bool, error) {
-	appliedMigrations, err := e.ListAppliedMigrations()
+func (e *Environment) VerifyExist() (VerificationReport, error) {
+	return e.VerifyExistContext(context.Background())
+}
+
+// VerifyExistContext checks that all applied migrations exist on disk, using the given context.
+func (e *Environment) VerifyExistContext(ctx context.Context) (VerificationReport, error) {
+	appliedMigrations, err := e.ListAppliedMigrationsContext(ctx)
 	if err != nil {
-		return false, err
+		return VerificationReport{}, err
 	}
 
-	for _, appliedMigration := range appliedMigrations {
-		_, err := e.GetMigrationByID(appliedMigration.ID)
-		if err != nil {
-			if err == ErrMigrationNotFound {
-				return false, nil
-			}
+	return e.verifyExist(appliedMigrations), nil
+}
 
-			return false, err
+// verifyExist checks that all of the given applied migrations exist on disk.
+func (e *Environment) verifyExist(appliedMigrations []AppliedMigration) VerificationReport {
+	report := VerificationReport{}
+	for _, appliedMigration := range appliedMigrations {
+		_, exists := e.migrationsByID[appliedMigration.ID]
+		if !exists {
+			e.logger.Warn("roamer: verification failed, applied migration does not exist", "id", appliedMigration.ID)
+			report.add(VerificationProblem{Kind: ErrMigrationMissing, ID: appliedMigration.ID})
 		}
 	}
 
-	return true, nil
+	return report
 }
 
 // VerifyOrder checks that the order of migrations on disk matches the order in the history.
-func (e *Environment) VerifyOrder() (bool, error) {
-	allMigrations, err := e.ListAllMigrations()
+// That is, every applied migration must exist on disk, and every migration before it on disk must also have been applied.
+//
+// If the environment allows out-of-order migrations, it only checks that every applied migration is on disk, and if the
+// migrations form a revision graph, that the parents of every applied migration have also been applied.
+func (e *Environment) VerifyOrder() (VerificationReport, error) {
+	return e.VerifyOrderContext(context.Background())
+}
+
+// VerifyOrderContext checks that the order of migrations on disk matches the order in the history, using the given context.
+func (e *Environment) VerifyOrderContext(ctx context.Context) (VerificationReport, error) {
+	appliedMigrations, err := e.ListAppliedMigrationsContext(ctx)
 	if err != nil {
-		return false, err
+		return VerificationReport{}, err
 	}
 
-	appliedMigrations, err := e.ListAppliedMigrations()
-	if err != nil {
-		return false, err
+	return e.verifyOrder(appliedMigrations), nil
+}
+
+// verifyOrder checks that the order of migrations on disk matches the order of the given applied migrations.
+func (e *Environment) verifyOrder(appliedMigrations []AppliedMigration) VerificationReport {
+	report := VerificationReport{}
+	applied := map[string]bool{}
+	for _, appliedMigration := range appliedMigrations {
+		_, exists := e.migrationsByID[appliedMigration.ID]
+		if !exists {
+			e.logger.Warn("roamer: verification failed, applied migration does not exist", "id", appliedMigration.ID)
+			report.add(VerificationProblem{Kind: ErrMigrationMissing, ID: appliedMigration.ID})
+		}
+
+		applied[appliedMigration.ID] = true
 	}
 
-	if len(appliedMigrations) > len(allMigrations) {
-		return false, nil
+	if e.AllowsOutOfOrder() {
+		if e.graph {
+			// the order doesn't matter, but nothing can be applied without its parents
+			for _, appliedMigration := range appliedMigrations {
+				for _, parent := range e.migrationsByID[appliedMigration.ID].Parents {
+					if !applied[parent] {
+						e.logger.Warn("roamer: verification failed, migration was applied without its parent", "id", appliedMigration.ID, "parentID", parent)
+						report.add(VerificationProblem{Kind: ErrMigrationParentNotApplied, ID: appliedMigration.ID, RelatedID: parent})
+					}
+				}
+			}
+		}
+
+		return report
 	}
 
-	for i, appliedMigration := range appliedMigrations {
-		if allMigrations[i].ID != appliedMigration.ID {
-			return false, nil
+	// the applied migrations must be the first ones on disk, so anything applied after a gap is out of order
+	firstUnapplied := ""
+	for _, migration := range e.migrations {
+		if !applied[migration.ID] {
+			if firstUnapplied == "" {
+				firstUnapplied = migration.ID
+			}
+			continue
+		}
+
+		if firstUnapplied != "" {
+			e.logger.Warn("roamer: verification failed, migrations were applied in a different order", "id", migration.ID, "expectedID", firstUnapplied)
+			report.add(VerificationProblem{Kind: ErrMigrationOutOfOrder, ID: migration.ID, RelatedID: firstUnapplied})
 		}
 	}
 
-	return true, nil
+	return report
 }
 
 // VerifySafeToApply checks that it is safe to apply migrations, running all other verification checks.
-func (e *Environment) VerifySafeToApply() (bool, error) {
-	noDirty, err := e.VerifyNoDirty()
-	if err != nil {
-		return false, err
-	}
-	if !noDirty {
-		return false, nil
-	}
+// The report has the problems found by all of the checks, with any that were found by more than one check only listed once.
+func (e *Environment) VerifySafeToApply() (VerificationReport, error) {
+	return e.VerifySafeToApplyContext(context.Background())
+}
 
-	exist, err := e.VerifyExist()
+// VerifySafeToApplyContext checks that it is safe to apply migrations, running all other verification checks, using the given context.
+func (e *Environment) VerifySafeToApplyContext(ctx context.Context) (VerificationReport, error) {
+	appliedMigrations, err := e.ListAppliedMigrationsContext(ctx)
 	if err != nil {
-		return false, err
-	}
-	if !exist {
-		return false, nil
+		return VerificationReport{}, err
 	}
 
-	order, err := e.VerifyOrder()
-	if err != nil {
-		return false, err
-	}
-	if !order {
-		return false, nil
+	report := VerificationReport{}
+	for _, checkReport := range []VerificationReport{
+		e.verifyNoDirty(appliedMigrations),
+		e.verifyExist(appliedMigrations),
+		e.verifyOrder(appliedMigrations),
+	} {
+		for _, problem := range checkReport.Problems {
+			report.add(problem)
+		}
 	}
 
-	return true, nil
+	return report, nil
 }
@@ -0,0 +1,104 @@
+package roamer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestVerificationError(t *testing.T) {
+	kinds := []error{ErrMigrationDirty, ErrMigrationMissing, ErrMigrationOutOfOrder, ErrMigrationParentNotApplied, ErrMigrationChanged}
+
+	tests := []struct {
+		problem  VerificationProblem
+		kindName string
+		message  string
+	}{
+		{
+			VerificationProblem{Kind: ErrMigrationDirty, ID: "3"},
+			"dirty",
+			"roamer: it is not safe to apply migrations: migration 3 is marked as dirty",
+		},
+		{
+			VerificationProblem{Kind: ErrMigrationMissing, ID: "3"},
+			"missing",
+			"roamer: it is not safe to apply migrations: applied migration 3 does not exist on disk",
+		},
+		{
+			VerificationProblem{Kind: ErrMigrationOutOfOrder, ID: "3", RelatedID: "2"},
+			"outOfOrder",
+			"roamer: it is not safe to apply migrations: migration 3 was applied before 2, which comes earlier on disk",
+		},
+		{
+			VerificationProblem{Kind: ErrMigrationParentNotApplied, ID: "3", RelatedID: "2"},
+			"parentNotApplied",
+			"roamer: it is not safe to apply migrations: migration 3 was applied without its parent 2",
+		},
+		{
+			VerificationProblem{Kind: ErrMigrationChanged, ID: "3"},
+			"changed",
+			"roamer: it is not safe to apply migrations: migration 3 has changed since it was applied",
+		},
+	}
+
+	for _, test := range tests {
+		report := VerificationReport{}
+		report.add(test.problem)
+		report.add(test.problem)
+
+		// the error might be wrapped on its way up, which shouldn't stop it from being matched
+		err := fmt.Errorf("upgrade: %w", report.Err())
+
+		if err.Error() != "upgrade: "+test.message {
+			t.Errorf("%s: got message %q, expected %q", test.kindName, err.Error(), "upgrade: "+test.message)
+		}
+		if test.problem.KindName() != test.kindName {
+			t.Errorf("%s: got kind name %s", test.kindName, test.problem.KindName())
+		}
+
+		if !errors.Is(err, ErrNotSafeToApply) {
+			t.Errorf("%s: expected the error to match ErrNotSafeToApply", test.kindName)
+		}
+		for _, kind := range kinds {
+			if errors.Is(err, kind) != (kind == test.problem.Kind) {
+				t.Errorf("%s: errors.Is(err, %q) returned %t", test.kindName, kind, !(kind == test.problem.Kind))
+			}
+		}
+
+		var verificationErr VerificationError
+		if !errors.As(err, &verificationErr) {
+			t.Fatalf("%s: expected a VerificationError", test.kindName)
+		}
+		unwrapped := verificationErr.Unwrap()
+		if len(unwrapped) != 1 || unwrapped[0] != test.problem {
+			t.Errorf("%s: expected the only problem to be unwrapped once, got %v", test.kindName, unwrapped)
+		}
+
+		var problem VerificationProblem
+		if !errors.As(err, &problem) || problem != test.problem {
+			t.Errorf("%s: expected errors.As to find the problem, got %+v", test.kindName, problem)
+		}
+	}
+}
+
+func TestVerificationErrorWithSeveralProblems(t *testing.T) {
+	report := VerificationReport{}
+	if report.Err() != nil {
+		t.Errorf("expected no error from an empty report, got %v", report.Err())
+	}
+
+	report.add(VerificationProblem{Kind: ErrMigrationDirty, ID: "3"})
+	report.add(VerificationProblem{Kind: ErrMigrationMissing, ID: "4"})
+	report.add(VerificationProblem{Kind: ErrMigrationDirty, ID: "5"})
+
+	err := report.Err()
+	if !errors.Is(err, ErrMigrationDirty) || !errors.Is(err, ErrMigrationMissing) || errors.Is(err, ErrMigrationChanged) {
+		t.Errorf("expected the error to match the kinds of its problems, and only those")
+	}
+	if len(err.(VerificationError).Unwrap()) != 3 {
+		t.Errorf("expected 3 unwrapped problems, got %v", err.(VerificationError).Unwrap())
+	}
+	if fmt.Sprint(report.IDs(ErrMigrationDirty)) != "[3 5]" {
+		t.Errorf("expected dirty migrations 3 and 5, got %v", report.IDs(ErrMigrationDirty))
+	}
+}